@@ -0,0 +1,341 @@
+// Package client is a Go client for the demo-ssb benchmark server's HTTP API.
+//
+//	c := client.NewClient("localhost:8000")
+//	results, err := c.Query("2.1", client.RunOptions{Concurrency: 8, BatchSize: 4})
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends requests to a demo-ssb server.
+type Client struct {
+	URL string // e.g. "http://localhost:8000"
+
+	// HTTPClient is used for requests. Benchmark runs can take minutes, so it has
+	// no timeout by default.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the server at addr, a host:port or URL.
+func NewClient(addr string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{URL: strings.TrimSuffix(addr, "/"), HTTPClient: &http.Client{}}
+}
+
+// Error codes of JSON error responses.
+const (
+	CodeUnknownQuery      = "unknown_query"
+	CodeUnknownQueryType  = "unknown_query_type"
+	CodeInvalidParameter  = "invalid_parameter"
+	CodeBusy              = "busy"
+	CodePilosaError       = "pilosa_error"
+	CodePilosaUnreachable = "pilosa_unreachable"
+	CodePilosaTimeout     = "pilosa_timeout"
+	CodeRunFailed         = "run_failed"
+	CodeUnknownSuite      = "unknown_suite"
+	CodeRunNotFound       = "run_not_found"
+	CodeNoBaseline        = "no_baseline"
+	CodeHistoryDisabled   = "history_disabled"
+	CodeInternal          = "internal"
+)
+
+// Error is returned when the server responds with an error status. Code and
+// Pilosa are set from the JSON error body.
+type Error struct {
+	StatusCode int
+	Code       string // one of the Code constants
+	Message    string
+	Pilosa     string // the error from Pilosa, if it caused the failure
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// RunFilter selects stored runs. Zero values match every run.
+type RunFilter struct {
+	Query       string
+	Cluster     string
+	Since       time.Time
+	Until       time.Time
+	Concurrency int
+	BatchSize   int
+	Limit       int
+}
+
+func (f RunFilter) values() url.Values {
+	v := url.Values{}
+	setString(v, "query", f.Query)
+	setString(v, "cluster", f.Cluster)
+	if !f.Since.IsZero() {
+		v.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		v.Set("until", f.Until.Format(time.RFC3339))
+	}
+	setInt(v, "concurrency", int64(f.Concurrency))
+	setInt(v, "batchsize", int64(f.BatchSize))
+	setInt(v, "limit", int64(f.Limit))
+	return v
+}
+
+// values returns opts as request parameters. False booleans are left out, so they
+// can't turn off an option the server enables by default.
+func (opts RunOptions) values() url.Values {
+	v := url.Values{}
+	setInt(v, "concurrency", int64(opts.Concurrency))
+	setInt(v, "batchsize", int64(opts.BatchSize))
+	setBool(v, "precompute", opts.Precompute)
+	setString(v, "order", opts.Order)
+	setInt(v, "seed", opts.Seed)
+	setString(v, "cluster", opts.Cluster)
+	setString(v, "balance", opts.Balance)
+	setBool(v, "profile", opts.Profile)
+	setBool(v, "profileheap", opts.ProfileHeap)
+	setString(v, "format", opts.Format)
+	setBool(v, "baseline", opts.Baseline)
+	setString(v, "metric", opts.Gate.Metric)
+	if opts.Gate.Tolerance != 0 {
+		v.Set("tolerance", strconv.FormatFloat(opts.Gate.Tolerance, 'g', -1, 64))
+	}
+	setBool(v, "verify", opts.Verify)
+	return v
+}
+
+// values returns p as request parameters. A policy with no metric leaves the
+// server's in place; otherwise the tolerance is sent even if it is 0.
+func (p RegressionPolicy) values() url.Values {
+	v := url.Values{}
+	if p.Metric != "" {
+		v.Set("metric", p.Metric)
+		v.Set("tolerance", strconv.FormatFloat(p.Tolerance, 'g', -1, 64))
+	}
+	return v
+}
+
+func setString(v url.Values, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
+
+func setInt(v url.Values, key string, value int64) {
+	if value != 0 {
+		v.Set(key, strconv.FormatInt(value, 10))
+	}
+}
+
+func setBool(v url.Values, key string, value bool) {
+	if value {
+		v.Set(key, "true")
+	}
+}
+
+// Version returns the demo and Pilosa versions.
+func (c *Client) Version() (VersionInfo, error) {
+	var info VersionInfo
+	err := c.get("/version", nil, &info)
+	return info, err
+}
+
+// Queue returns the benchmark runs in progress and waiting.
+func (c *Client) Queue() (QueueState, error) {
+	var state QueueState
+	err := c.get("/queue", nil, &state)
+	return state, err
+}
+
+// Queries lists the query sets and suites.
+func (c *Client) Queries() (QueryList, error) {
+	var list QueryList
+	err := c.get("/queries", nil, &list)
+	return list, err
+}
+
+// Query runs the named query set once, waiting behind any other runs.
+func (c *Client) Query(name string, opts RunOptions) ([]BenchmarkResult, error) {
+	var results []BenchmarkResult
+	err := c.get("/query/"+url.PathEscape(name), opts.values(), &results)
+	return results, err
+}
+
+// TryQuery runs the named query set once if no other run is in progress or
+// queued, and otherwise fails with an *Error with Code CodeBusy.
+func (c *Client) TryQuery(name string, opts RunOptions) ([]BenchmarkResult, error) {
+	v := opts.values()
+	v.Set("wait", "false")
+	var results []BenchmarkResult
+	err := c.get("/query/"+url.PathEscape(name), v, &results)
+	return results, err
+}
+
+// Grid runs the named query set at concurrency 8, 16 and 32 by batch size 2, 4
+// and 8. The concurrency and batch size of opts are ignored.
+func (c *Client) Grid(name string, opts RunOptions) ([]BenchmarkResult, error) {
+	var results []BenchmarkResult
+	err := c.get("/grid/"+url.PathEscape(name), opts.values(), &results)
+	return results, err
+}
+
+// Suite runs the named suite, or the ssb suite if name is empty.
+func (c *Client) Suite(name string, opts RunOptions) (SuiteResult, error) {
+	path := "/suite"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	var result SuiteResult
+	err := c.get(path, opts.values(), &result)
+	return result, err
+}
+
+// CompareOptions are the settings of a comparison across clusters. Zero values
+// leave the server's defaults in place.
+type CompareOptions struct {
+	RunOptions
+	Clusters []string // the first is the baseline; default all, starting with the default cluster
+	Mode     string   // "interleaved" or "sequential"
+	Rounds   int
+}
+
+// Compare runs the named query set on several clusters and compares their timings
+// and results.
+func (c *Client) Compare(name string, opts CompareOptions) (Comparison, error) {
+	v := opts.RunOptions.values()
+	if len(opts.Clusters) > 0 {
+		v.Set("clusters", strings.Join(opts.Clusters, ","))
+	}
+	setString(v, "mode", opts.Mode)
+	setInt(v, "rounds", int64(opts.Rounds))
+	var result Comparison
+	err := c.get("/compare/"+url.PathEscape(name), v, &result)
+	return result, err
+}
+
+// Workload sends queries queries from a weighted mix of query sets, such as
+// "40:2.x,30:3.x,30:4.x". The seed, concurrency, cluster and balance of opts
+// apply; zero queries or options leave the server's defaults.
+func (c *Client) Workload(mix string, queries int, opts RunOptions) (WorkloadResult, error) {
+	v := opts.values()
+	v.Set("mix", mix)
+	setInt(v, "queries", int64(queries))
+	var result WorkloadResult
+	err := c.get("/workload", v, &result)
+	return result, err
+}
+
+// Runs lists the stored runs matching f, newest first.
+func (c *Client) Runs(f RunFilter) ([]StoredRun, error) {
+	var runs []StoredRun
+	err := c.get("/runs", f.values(), &runs)
+	return runs, err
+}
+
+// Run returns a stored run.
+func (c *Client) Run(id string) (StoredRun, error) {
+	var run StoredRun
+	err := c.get("/runs/"+url.PathEscape(id), nil, &run)
+	return run, err
+}
+
+// RunResults returns the result rows of a stored run, sorted by input.
+func (c *Client) RunResults(id string) (RunRows, error) {
+	var rows RunRows
+	err := c.get("/runs/"+url.PathEscape(id)+"/results", nil, &rows)
+	return rows, err
+}
+
+// Diff compares the result rows of stored runs a and b.
+func (c *Client) Diff(a, b string) (RunDiff, error) {
+	var diff RunDiff
+	err := c.get("/runs/"+url.PathEscape(a)+"/diff/"+url.PathEscape(b), nil, &diff)
+	return diff, err
+}
+
+// SetBaseline makes a stored run the baseline of its query.
+func (c *Client) SetBaseline(id string) error {
+	return c.do("POST", "/runs/"+url.PathEscape(id)+"/baseline", nil, nil)
+}
+
+// Baselines returns the baseline run ID of each query.
+func (c *Client) Baselines() (map[string]string, error) {
+	var baselines map[string]string
+	err := c.get("/baselines", nil, &baselines)
+	return baselines, err
+}
+
+// Gate checks the given stored runs against the baselines of their queries, or
+// with no runs, the latest run of every query with a baseline. A zero policy
+// uses the server's.
+func (c *Client) Gate(ids []string, policy RegressionPolicy) (GateResult, error) {
+	v := policy.values()
+	if len(ids) > 0 {
+		v.Set("runs", strings.Join(ids, ","))
+	}
+	var gate GateResult
+	err := c.get("/gate", v, &gate)
+	return gate, err
+}
+
+// Report returns an HTML report of the given runs. A zero policy uses the
+// server's for the comparisons against baselines.
+func (c *Client) Report(ids []string, policy RegressionPolicy) ([]byte, error) {
+	v := policy.values()
+	v.Set("runs", strings.Join(ids, ","))
+	var report []byte
+	err := c.do("GET", "/report", v, &report)
+	return report, err
+}
+
+func (c *Client) get(path string, params url.Values, v interface{}) error {
+	return c.do("GET", path, params, v)
+}
+
+// do sends a request and decodes a JSON response into v, or, if v is a *[]byte,
+// copies the body into it.
+func (c *Client) do(method, path string, params url.Values, v interface{}) error {
+	u := c.URL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		e := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		var er ErrorResponse
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(body, &er) == nil {
+			e.Code, e.Message, e.Pilosa = er.Error.Code, er.Error.Message, er.Error.Pilosa
+		}
+		return e
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*v, err = ioutil.ReadAll(resp.Body)
+		return err
+	default:
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("decoding %v response: %v", path, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,411 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// The types below are the JSON bodies of the server's requests and responses,
+// shared by the server so the two can't drift apart; see /openapi.json.
+
+// VersionInfo is the response of /version.
+type VersionInfo struct {
+	DemoVersion   string `json:"demoversion"`
+	PilosaVersion string `json:"pilosaversion"`
+}
+
+// APIError is the body of a JSON error response, as {"error": APIError}.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"` // one of the Code constants, e.g. "unknown_query"
+	Message string `json:"message"`
+	Pilosa  string `json:"pilosa,omitempty"` // the error from Pilosa, if it caused the failure
+}
+
+// ErrorResponse is the JSON body of error responses.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// RunOptions holds the per-request settings of a benchmark run. As request
+// parameters, zero values leave the server's defaults in place. Server-wide
+// settings such as the error policy and timeouts are in ServerSettings.
+type RunOptions struct {
+	Concurrency int              `json:"concurrency"`
+	BatchSize   int              `json:"batchsize"`
+	Precompute  bool             `json:"precompute"`  // build every query and batch body before the timer starts
+	Order       string           `json:"order"`       // "sequential", "random" or "reverse"
+	Seed        int64            `json:"seed"`        // seed for the random order
+	Cluster     string           `json:"cluster"`     // name of the cluster to run against
+	Balance     string           `json:"balance"`     // how batches are spread across the cluster's nodes
+	Profile     bool             `json:"profile"`     // fetch CPU profiles from every node during the run
+	ProfileHeap bool             `json:"profileheap"` // also fetch a heap profile from every node after the run
+	Format      string           `json:"format"`      // results file format: "txt", "csv", "jsonl" or "arrow"
+	Baseline    bool             `json:"baseline"`    // make this run the baseline for its query if it succeeds
+	Gate        RegressionPolicy `json:"gate"`        // how the run is compared against its baseline
+	Verify      bool             `json:"verify"`      // compare the result rows against the golden answers
+}
+
+// RegressionPolicy decides when a run is slower than its baseline by too much.
+type RegressionPolicy struct {
+	Metric    string  `json:"metric"`    // "mean" or "p99" batch latency
+	Tolerance float64 `json:"tolerance"` // allowed slowdown as a fraction of the baseline, e.g. 0.1 for 10%
+}
+
+// BenchmarkResult is the result of running one query set.
+type BenchmarkResult struct {
+	RunID       string  `json:"runid"` // base name of the results file
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	Concurrency int     `json:"concurrency"`
+	BatchSize   int     `json:"batchsize"`
+	Seconds     float64 `json:"seconds"`
+	ColumnCount uint64  `json:"columncount"`
+	Timestamp   int32   `json:"timestamp"`
+
+	// Error is why the run failed, when Seconds is -1, and Code classifies it like
+	// the code of a JSON error response, e.g. "pilosa_timeout".
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+
+	// Partial is set when the run finished without every query completing: some
+	// failed and were skipped, timed out, or were never sent before the run
+	// deadline.
+	Partial bool `json:"partial"`
+
+	// Error accounting, populated according to the run's ErrorPolicy.
+	Aborted      bool            `json:"aborted"`
+	Retries      int             `json:"retries"`
+	Failed       int             `json:"failed"`
+	Errors       map[string]int  `json:"errors,omitempty"`
+	FailedInputs [][]interface{} `json:"failedinputs,omitempty"`
+
+	// Timeout accounting. Timed-out queries are not counted as failures.
+	Completed        int             `json:"completed"`
+	TimedOut         int             `json:"timedout"`
+	TimedOutInputs   [][]interface{} `json:"timedoutinputs,omitempty"`
+	DeadlineExceeded bool            `json:"deadlineexceeded"`
+
+	// Overlapped is set when another benchmark request ran at the same time as this
+	// run's request, up to the end of the run.
+	Overlapped bool `json:"overlapped"`
+
+	Cluster     string     `json:"cluster"`
+	Balance     string     `json:"balance"`
+	Precomputed bool       `json:"precomputed"`
+	Order       string     `json:"order"`
+	Seed        int64      `json:"seed"`
+	Phases      PhaseTimes `json:"phases"`
+
+	// Latency has the request latencies of every batch, and Nodes those of each node
+	// batches were sent to. Each request is one batch, so Queries counts batches here.
+	Latency LatencyStats   `json:"latency"`
+	Nodes   []LatencyStats `json:"nodes,omitempty"`
+
+	// Meta describes the environment and settings of the run.
+	Meta RunMetadata `json:"meta"`
+
+	// Verification compares the result rows against the golden answers of the query, in verify mode.
+	Verification *Verification `json:"verification,omitempty"`
+
+	// Regression compares the run against the baseline run of its query, if any.
+	Regression *RegressionCheck `json:"regression,omitempty"`
+
+	// Server has the change in Pilosa's expvar metrics over the run.
+	Server *ServerMetrics `json:"server,omitempty"`
+
+	// Profiles lists the pprof files captured from Pilosa during the run.
+	Profiles      []string `json:"profiles,omitempty"`
+	ProfileErrors []string `json:"profileerrors,omitempty"`
+}
+
+// PhaseTimes breaks the work of a run into harness phases, in seconds, to separate
+// the harness's own work from its requests to Pilosa. Request is the HTTP round
+// trip of each batch: the network and Pilosa's execution, up to reading the whole
+// response. Decode is unmarshaling the protobuf responses. Neither includes the
+// backoff between retries. Phases performed by workers are summed across workers,
+// so with concurrency > 1 they can add up to more than Seconds.
+type PhaseTimes struct {
+	Generate  float64 `json:"generate"`  // formatting query strings from the QuerySet
+	Serialize float64 `json:"serialize"` // concatenating queries into batch PQL
+	Request   float64 `json:"request"`   // HTTP requests to Pilosa, including retries
+	Decode    float64 `json:"decode"`    // decoding protobuf responses
+	Collate   float64 `json:"collate"`   // collating response Sums with their inputs
+	Write     float64 `json:"write"`     // formatting and writing the results file
+}
+
+// LatencyStats summarizes query latencies, in seconds.
+type LatencyStats struct {
+	Name     string  `json:"name"`
+	Queries  int     `json:"queries"`
+	Failed   int     `json:"failed"`
+	TimedOut int     `json:"timedout"`
+	Mean     float64 `json:"mean"`
+	Min      float64 `json:"min"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+	Max      float64 `json:"max"`
+}
+
+// RunMetadata records what a run was executed with and against, so results can be
+// compared across harness versions, Pilosa upgrades and machines.
+type RunMetadata struct {
+	DemoVersion   string   `json:"demoversion"`
+	PilosaVersion string   `json:"pilosaversion"`
+	PilosaAddr    string   `json:"pilosaaddr"`
+	NodeCount     int      `json:"nodecount"`
+	Nodes         []string `json:"nodes"`
+	Index         string   `json:"index"`
+	Slices        uint64   `json:"slices"` // number of slices in the index, 0 if unknown
+
+	Hostname  string `json:"hostname"`
+	GoVersion string `json:"goversion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	NumCPU    int    `json:"numcpu"`
+
+	// QueryHash identifies the exact queries run: a SHA-256 of the query template,
+	// setup, teardown and arguments.
+	QueryHash string `json:"queryhash"`
+	PQL       string `json:"pql"` // the query template
+	Setup     string `json:"setup,omitempty"`
+	Teardown  string `json:"teardown,omitempty"`
+
+	Options  RunOptions     `json:"options"`
+	Settings ServerSettings `json:"settings"`
+
+	// Errors lists metadata that could not be fetched from Pilosa.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ServerSettings are the server-wide flags that apply to every run. Durations are
+// in seconds, with 0 for no limit.
+type ServerSettings struct {
+	Retries         int     `json:"retries"`
+	Backoff         float64 `json:"backoff"`
+	ContinueOnError bool    `json:"continueonerror"`
+	BatchTimeout    float64 `json:"batchtimeout"`
+	RunTimeout      float64 `json:"runtimeout"`
+	ServerMetrics   bool    `json:"servermetrics"`
+	ParallelRuns    bool    `json:"parallelruns"` // runs may overlap instead of queueing
+}
+
+// Verification compares the result rows of a run against the golden answers of
+// its query. Missing groups are expected answers the run did not return, e.g.
+// because their batch failed or timed out; extra groups have no expected answer.
+type Verification struct {
+	Golden      string      `json:"golden"`
+	ScaleFactor int         `json:"scalefactor"`
+	Pass        bool        `json:"pass"`
+	Error       string      `json:"error,omitempty"`
+	Summary     DiffSummary `json:"summary"`
+	Missing     []ResultRow `json:"missing,omitempty"`
+	Extra       []ResultRow `json:"extra,omitempty"`
+	Mismatches  []ValueDiff `json:"mismatches,omitempty"` // a is the expected value, b the run's
+}
+
+// RegressionCheck is the verdict of comparing a run against the baseline of its query.
+type RegressionCheck struct {
+	Query         string           `json:"query"`
+	Run           string           `json:"run"`
+	Baseline      string           `json:"baseline"`
+	Policy        RegressionPolicy `json:"policy"`
+	BaselineValue float64          `json:"baselinevalue"`
+	Value         float64          `json:"value"`
+	Change        float64          `json:"change"` // (value - baseline) / baseline
+	Pass          bool             `json:"pass"`
+	Warnings      []string         `json:"warnings,omitempty"`
+}
+
+// ServerMetrics describes what happened inside the Pilosa cluster during a run.
+type ServerMetrics struct {
+	StateBefore string        `json:"statebefore"`
+	StateAfter  string        `json:"stateafter"`
+	Nodes       []NodeMetrics `json:"nodes"`
+}
+
+// NodeMetrics is the change in a node's server-side metrics over a run. Queries
+// and CacheHits sum the deltas of every counter whose name mentions "query" or
+// "hit", since the exact expvar names vary between Pilosa versions; Deltas has
+// every value that changed.
+type NodeMetrics struct {
+	Node       string             `json:"node"`
+	Queries    float64            `json:"queries"`
+	CacheHits  float64            `json:"cachehits"`
+	Goroutines int                `json:"goroutines"`
+	HeapAlloc  float64            `json:"heapalloc"`
+	Sys        float64            `json:"sys"`
+	NumGC      float64            `json:"numgc"`
+	Deltas     map[string]float64 `json:"deltas,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// SuiteResult is the result of running a suite of query sets.
+type SuiteResult struct {
+	Name        string            `json:"name"`
+	Concurrency int               `json:"concurrency"`
+	BatchSize   int               `json:"batchsize"`
+	Seconds     float64           `json:"seconds"`
+	GeoMean     float64           `json:"geomean"`
+	Failed      int               `json:"failed"`
+	Overlapped  bool              `json:"overlapped"`
+	Timestamp   int32             `json:"timestamp"`
+	Results     []BenchmarkResult `json:"results"`
+
+	// Unverified lists the queries whose results did not match their golden answers.
+	Unverified []string `json:"unverified,omitempty"`
+
+	// Gate combines the regression checks of the queries that have a baseline.
+	Gate *GateResult `json:"gate,omitempty"`
+}
+
+// GateResult is the combined verdict over several RegressionChecks.
+type GateResult struct {
+	Pass   bool              `json:"pass"`
+	Checks []RegressionCheck `json:"checks"`
+}
+
+// Comparison is the result of running a query set on several clusters.
+type Comparison struct {
+	Name       string          `json:"name"`
+	Mode       string          `json:"mode"`
+	Rounds     int             `json:"rounds"`
+	Baseline   string          `json:"baseline"`
+	Timestamp  int32           `json:"timestamp"`
+	Clusters   []ClusterTiming `json:"clusters"`
+	Mismatches []RowMismatch   `json:"mismatches"`
+}
+
+// ClusterTiming summarizes the runs of a QuerySet against one cluster.
+type ClusterTiming struct {
+	Cluster string            `json:"cluster"`
+	Mean    float64           `json:"mean"`
+	Speedup *float64          `json:"speedup"` // baseline mean / this cluster's mean; null if either has no successful run
+	Results []BenchmarkResult `json:"results"` // one per round
+
+	// Error is set when no round completed every query, in which case the
+	// cluster's rows are left out of the comparison.
+	Error string `json:"error,omitempty"`
+}
+
+// RowMismatch is a query whose result differs between clusters. Values has no
+// entry for a cluster that did not return the row.
+type RowMismatch struct {
+	Inputs []int          `json:"inputs"`
+	Values map[string]int `json:"values"`
+}
+
+// WorkloadResult is the outcome of a workload run. Queries counts the queries that
+// completed, failed or timed out, which is fewer than requested if the run was
+// aborted or hit its deadline.
+type WorkloadResult struct {
+	Mix              string         `json:"mix"`
+	Queries          int            `json:"queries"`
+	Completed        int            `json:"completed"`
+	Concurrency      int            `json:"concurrency"`
+	Seed             int64          `json:"seed"`
+	Cluster          string         `json:"cluster"`
+	Seconds          float64        `json:"seconds"`
+	QueriesPerSecond float64        `json:"qps"`
+	ColumnCount      uint64         `json:"columncount"`
+	Timestamp        int32          `json:"timestamp"`
+	Aborted          bool           `json:"aborted"`
+	DeadlineExceeded bool           `json:"deadlineexceeded"`
+	Failed           int            `json:"failed"`
+	TimedOut         int            `json:"timedout"`
+	Overlapped       bool           `json:"overlapped"`
+	Sets             []LatencyStats `json:"sets"`
+}
+
+// QueueState lists the benchmark runs in progress and waiting.
+type QueueState struct {
+	Exclusive bool        `json:"exclusive"`
+	Running   []QueuedRun `json:"running"`
+	Waiting   []QueuedRun `json:"waiting"`
+}
+
+// QueuedRun is a benchmark request waiting for, or holding, a slot in the
+// server's run queue.
+type QueuedRun struct {
+	ID         uint64    `json:"id"`
+	Name       string    `json:"name"`
+	Queued     time.Time `json:"queued"`
+	Started    time.Time `json:"started"`
+	Overlapped bool      `json:"overlapped"`
+
+	// Progress of the query currently running, for runs of several queries.
+	Query string `json:"query"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// QueryList is the response of /queries.
+type QueryList struct {
+	Queries []QueryInfo         `json:"queries"`
+	Suites  map[string][]string `json:"suites"`
+}
+
+// QueryInfo describes a QuerySet for clients such as the dashboard.
+type QueryInfo struct {
+	Name       string   `json:"name"`
+	Labels     []string `json:"labels"`
+	ArgSets    [][]int  `json:"argsets"`
+	Iterations int      `json:"iterations"`
+	PQL        string   `json:"pql"` // query template, with %d for each input
+}
+
+// StoredRun is a BenchmarkResult, whose Meta has the settings and environment it
+// ran with. Result rows are stored separately so listing runs stays cheap.
+type StoredRun struct {
+	ID     string          `json:"id"`
+	Time   time.Time       `json:"time"`
+	Result BenchmarkResult `json:"result"`
+}
+
+// RunRows is the labelled result rows of a stored run.
+type RunRows struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Labels []string    `json:"labels"`
+	Rows   []ResultRow `json:"rows"`
+}
+
+// ResultRow is the output of a single query, keyed by its QuerySet inputs.
+type ResultRow struct {
+	Inputs []int `json:"inputs"`
+	Value  int   `json:"value"`
+}
+
+// Key identifies the row's inputs, for joining rows of different runs.
+func (r ResultRow) Key() string {
+	return fmt.Sprint(r.Inputs)
+}
+
+// RunDiff compares the result rows of run A against run B, joined on their inputs.
+type RunDiff struct {
+	A       string      `json:"a"`
+	B       string      `json:"b"`
+	Name    string      `json:"name"`
+	Labels  []string    `json:"labels"`
+	Summary DiffSummary `json:"summary"`
+	Missing []ResultRow `json:"missing"`
+	Extra   []ResultRow `json:"extra"`
+	Changed []ValueDiff `json:"changed"`
+}
+
+// DiffSummary counts the groups of a RunDiff.
+type DiffSummary struct {
+	Common  int  `json:"common"`  // groups present in both runs
+	Missing int  `json:"missing"` // groups in A but not in B
+	Extra   int  `json:"extra"`   // groups in B but not in A
+	Changed int  `json:"changed"` // common groups with different values
+	Equal   bool `json:"equal"`
+}
+
+// ValueDiff is a group whose result differs between two runs.
+type ValueDiff struct {
+	Inputs []int `json:"inputs"`
+	A      int   `json:"a"`
+	B      int   `json:"b"`
+}
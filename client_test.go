@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// TestClient sends the client's run history requests to the server's handlers.
+func TestClient(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	s := &Server{
+		Cluster: &Cluster{Name: "default"},
+		queue:   NewRunQueue(true),
+		gate:    RegressionPolicy{Metric: "mean", Tolerance: 0.1},
+		store:   store,
+	}
+	s.metrics = newHarnessMetrics(s)
+	if s.Router, err = s.routes(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2017, 11, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"1.1-a", "1.1-b"} {
+		run := StoredRun{ID: id, Time: start.Add(time.Duration(i) * time.Hour), Result: BenchmarkResult{
+			RunID: id, Name: "1.1", Seconds: 1, Latency: LatencyStats{Mean: 1 + float64(i)},
+		}}
+		if err := store.SaveRun(run, []ResultRow{{Inputs: []int{1993}, Value: 10 + i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(s.Router)
+	defer srv.Close()
+	c := client.NewClient(srv.URL)
+
+	if err := c.SetBaseline("1.1-a"); err != nil {
+		t.Fatal(err)
+	}
+	if baselines, err := c.Baselines(); err != nil || !reflect.DeepEqual(baselines, map[string]string{"1.1": "1.1-a"}) {
+		t.Errorf("got baselines %v, %v, want 1.1-a for 1.1", baselines, err)
+	}
+	if runs, err := c.Runs(client.RunFilter{Query: "1.1", Limit: 1}); err != nil || len(runs) != 1 || runs[0].ID != "1.1-b" {
+		t.Errorf("got runs %+v, %v, want 1.1-b", runs, err)
+	}
+	if run, err := c.Run("1.1-a"); err != nil || run.ID != "1.1-a" || !run.Time.Equal(start) {
+		t.Errorf("got run %+v, %v, want 1.1-a at %v", run, err, start)
+	}
+	if rows, err := c.RunResults("1.1-b"); err != nil || !reflect.DeepEqual(rows.Rows, []ResultRow{{Inputs: []int{1993}, Value: 11}}) {
+		t.Errorf("got rows %+v, %v", rows, err)
+	}
+	if d, err := c.Diff("1.1-a", "1.1-b"); err != nil || d.Summary.Changed != 1 {
+		t.Errorf("got diff %+v, %v, want one changed row", d, err)
+	}
+	if gate, err := c.Gate(nil, RegressionPolicy{Metric: "mean", Tolerance: 0.5}); err != nil || gate.Pass || len(gate.Checks) != 1 {
+		t.Errorf("got gate %+v, %v, want one failed check", gate, err)
+	}
+	if gate, err := c.Gate([]string{"1.1-b"}, RegressionPolicy{Metric: "mean", Tolerance: 1}); err != nil || !gate.Pass {
+		t.Errorf("got gate %+v, %v, want a pass", gate, err)
+	}
+	if _, err := c.Queue(); err != nil {
+		t.Error(err)
+	}
+
+	_, err = c.Run("1.1-z")
+	if e, ok := err.(*client.Error); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown run: got %v, want a 404 client.Error", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	pilosa "github.com/pilosa/go-pilosa"
+)
+
+// Cluster is a named connection to the SSB index on a Pilosa cluster.
+type Cluster struct {
+	Name          string
+	Addr          string
+	Client        *pilosa.Client
+	Index         *pilosa.Index
+	Frames        map[string]*pilosa.Frame
+	NumLineOrders uint64
+
+	// Nodes that batches are spread across; the first is the node at Addr.
+	Nodes []*Node
+	next  uint64 // round-robin position, accessed atomically
+}
+
+func NewCluster(name, pilosaAddr, indexName string) (*Cluster, error) {
+	cluster := &Cluster{
+		Name:   name,
+		Addr:   pilosaAddr,
+		Frames: make(map[string]*pilosa.Frame),
+	}
+
+	pilosaURI, err := pilosa.NewURIFromAddress(pilosaAddr)
+	if err != nil {
+		return nil, err
+	}
+	client := pilosa.NewClientWithURI(pilosaURI)
+	index, err := pilosa.NewIndex(indexName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pilosa.NewIndex: %v", err)
+	}
+	err = client.EnsureIndex(index)
+	if err != nil {
+		return nil, fmt.Errorf("client.EnsureIndex: %v", err)
+	}
+
+	// TODO should be automatic from /schema
+	frames := []string{
+		"lo_quantity", // these frames X each have one field, field_X
+		"lo_quantity_b",
+		"lo_extendedprice",
+		"lo_discount",
+		"lo_discount_b",
+		"lo_revenue",
+		"lo_supplycost",
+		"lo_profit",
+		"lo_revenue_computed",
+		"c_city",
+		"c_nation",
+		"c_region",
+		"s_city",
+		"s_nation",
+		"s_region",
+		"p_mfgr",
+		"p_category",
+		"p_brand1",
+		"lo_year",
+		"lo_month",
+		"lo_weeknum",
+	}
+
+	for _, frameName := range frames {
+		frame, err := index.Frame(frameName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("index.Frame %v: %v", frameName, err)
+		}
+		err = client.EnsureFrame(frame)
+		if err != nil {
+			return nil, fmt.Errorf("client.EnsureFrame %v: %v", frameName, err)
+		}
+
+		cluster.Frames[frameName] = frame
+	}
+
+	cluster.Client = client
+	cluster.Index = index
+	cluster.Nodes = []*Node{newNode(pilosaAddr, indexName)}
+	cluster.NumLineOrders = cluster.getLineOrderCount()
+	return cluster, nil
+}
+
+// getLineOrderCount counts lineorders as the sum of the p_mfgr rows of the five
+// manufacturers, which are numbered from 0.
+func (c *Cluster) getLineOrderCount() uint64 {
+	var count uint64 = 0
+	for n := 0; n < 5; n++ {
+		q := c.Index.Count(c.Frames["p_mfgr"].Bitmap(uint64(n)))
+		response, err := c.Client.Query(q, nil)
+		if err != nil {
+			fmt.Printf("in getLineOrderCount: %v\n", err)
+			return 666
+		}
+		count += response.Result().Count
+	}
+	return count
+}
+
+// AddCluster connects to another Pilosa cluster, using the same index as the
+// default cluster, so that runs can target it by name.
+func (s *Server) AddCluster(name, pilosaAddr string) error {
+	if _, ok := s.Clusters[name]; ok {
+		return fmt.Errorf("duplicate cluster name: %v", name)
+	}
+	cluster, err := NewCluster(name, pilosaAddr, s.Index.Name())
+	if err != nil {
+		return fmt.Errorf("connecting to cluster %v: %v", name, err)
+	}
+	s.Clusters[name] = cluster
+	return nil
+}
+
+// ClusterNames returns the names of all configured clusters, sorted.
+func (s *Server) ClusterNames() []string {
+	names := make([]string, 0, len(s.Clusters))
+	for name := range s.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
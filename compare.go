@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RunComparison runs qs against each named cluster for the given number of rounds,
+// either interleaved (A, B, A, B, ...) or sequentially (A, A, ..., B, B, ...). The
+// first cluster is the baseline for speedups. Result rows from each cluster's first
+// complete round are compared against each other; a cluster with no complete round
+// gets an Error instead.
+func (s *Server) RunComparison(qs QuerySet, clusters []string, mode string, rounds int, opts RunOptions, run *QueuedRun) Comparison {
+	cmp := Comparison{
+		Name:      qs.Name,
+		Mode:      mode,
+		Rounds:    rounds,
+		Baseline:  clusters[0],
+		Timestamp: int32(time.Now().Unix()),
+		Clusters:  make([]ClusterTiming, len(clusters)),
+	}
+	runs := make([][]benchmarkRun, len(clusters))
+	runOn := func(i int) {
+		opts.Cluster = clusters[i]
+		res := s.RunSumMultiBatch(qs, opts, run)
+		runs[i] = append(runs[i], res)
+		cmp.Clusters[i].Cluster = clusters[i]
+		cmp.Clusters[i].Results = append(cmp.Clusters[i].Results, res.BenchmarkResult)
+	}
+	if mode == "interleaved" {
+		for r := 0; r < rounds; r++ {
+			for i := range clusters {
+				runOn(i)
+			}
+		}
+	} else {
+		for i := range clusters {
+			for r := 0; r < rounds; r++ {
+				runOn(i)
+			}
+		}
+	}
+
+	for i := range cmp.Clusters {
+		ct := &cmp.Clusters[i]
+		n := 0
+		for _, res := range ct.Results {
+			if res.Seconds > 0 {
+				ct.Mean += res.Seconds
+				n++
+			}
+		}
+		if n > 0 {
+			ct.Mean /= float64(n)
+		}
+	}
+	for i := range cmp.Clusters {
+		if cmp.Clusters[0].Mean > 0 && cmp.Clusters[i].Mean > 0 {
+			speedup := cmp.Clusters[0].Mean / cmp.Clusters[i].Mean
+			cmp.Clusters[i].Speedup = &speedup
+		}
+	}
+
+	// Join the rows of each cluster's first complete round on their inputs.
+	inputs := make(map[string][]int)
+	values := make(map[string]map[string]int)
+	var compared []string
+	for i := range cmp.Clusters {
+		ct := &cmp.Clusters[i]
+		ref, err := referenceRun(runs[i])
+		if err != nil {
+			ct.Error = err.Error()
+			continue
+		}
+		compared = append(compared, ct.Cluster)
+		for _, row := range ref.rows {
+			key := row.Key()
+			if values[key] == nil {
+				inputs[key] = row.Inputs
+				values[key] = make(map[string]int)
+			}
+			values[key][ct.Cluster] = row.Value
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmp.Mismatches = []RowMismatch{}
+	for _, key := range keys {
+		vals := values[key]
+		mismatch := len(vals) != len(compared)
+		for _, v := range vals {
+			mismatch = mismatch || v != vals[compared[0]]
+		}
+		if mismatch {
+			cmp.Mismatches = append(cmp.Mismatches, RowMismatch{Inputs: inputs[key], Values: vals})
+		}
+	}
+	return cmp
+}
+
+// referenceRun returns the first of runs in which every query completed.
+func referenceRun(runs []benchmarkRun) (benchmarkRun, error) {
+	var err error
+	for _, res := range runs {
+		switch {
+		case res.Seconds < 0:
+			err = fmt.Errorf("run failed: %v", res.Error)
+		case res.Aborted:
+			err = fmt.Errorf("run aborted after %d failed queries", res.Failed)
+		case res.Failed > 0 || res.TimedOut > 0:
+			err = fmt.Errorf("%d queries failed and %d timed out", res.Failed, res.TimedOut)
+		default:
+			return res, nil
+		}
+	}
+	return benchmarkRun{}, err
+}
+
+// HandleCompare runs a query against several clusters, e.g.
+// /compare/2.1?clusters=default,new&mode=interleaved&rounds=3
+func (s *Server) HandleCompare(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("handling %v\n", r.URL)
+	qs := getQuerySet(mux.Vars(r)["qname"])
+	if qs.Name == "" {
+		writeError(w, APIError{
+			Status:  http.StatusNotFound,
+			Code:    codeUnknownQuery,
+			Message: fmt.Sprintf("unknown query: %q", mux.Vars(r)["qname"]),
+		})
+		return
+	}
+	opts, err := s.runOptions(r)
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+
+	params := r.URL.Query()
+	clusters := []string{s.Cluster.Name}
+	for _, name := range s.ClusterNames() {
+		if name != s.Cluster.Name {
+			clusters = append(clusters, name)
+		}
+	}
+	if v := params.Get("clusters"); v != "" {
+		clusters = strings.Split(v, ",")
+	}
+	seen := make(map[string]bool)
+	for _, name := range clusters {
+		if _, ok := s.Clusters[name]; !ok {
+			writeError(w, invalidParameter(fmt.Errorf("unknown cluster: %q", name)))
+			return
+		}
+		if seen[name] {
+			writeError(w, invalidParameter(fmt.Errorf("duplicate cluster: %q", name)))
+			return
+		}
+		seen[name] = true
+	}
+	if len(clusters) < 2 {
+		writeError(w, invalidParameter(errors.New("comparison needs at least two clusters; add them with --cluster")))
+		return
+	}
+	mode := "interleaved"
+	if v := params.Get("mode"); v != "" {
+		if v != "interleaved" && v != "sequential" {
+			writeError(w, invalidParameter(fmt.Errorf("invalid mode: %q, expected interleaved or sequential", v)))
+			return
+		}
+		mode = v
+	}
+	rounds := 1
+	if v := params.Get("rounds"); v != "" {
+		if rounds, err = strconv.Atoi(v); err != nil || rounds <= 0 {
+			writeError(w, invalidParameter(fmt.Errorf("invalid rounds: %q", v)))
+			return
+		}
+	}
+
+	run, err := s.queue.Acquire(r.Context(), r.URL.Path)
+	if err != nil {
+		fmt.Printf("%v left the queue: %v\n", r.URL, err)
+		return
+	}
+	result := s.RunComparison(qs, clusters, mode, rounds, opts, run)
+	s.queue.Release(run)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		fmt.Printf("writing comparison: %v to responsewriter: %v", result, err)
+	}
+}
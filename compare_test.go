@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRunComparisonSpeedup(t *testing.T) {
+	qs := NewQuerySet("test", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_year", rowID=%d))`, [][]int{{1, 2}})
+	opts := RunOptions{Concurrency: 1, BatchSize: 1, Order: "sequential", Balance: "round-robin", Format: "txt"}
+	tests := []struct {
+		name        string
+		failing     string // the cluster whose Pilosa fails every query, if any
+		wantSpeedup []bool // whether each cluster has a speedup
+	}{
+		{"both succeed", "", []bool{true, true}},
+		{"baseline failed", "default", []bool{false, false}},
+		{"other failed", "new", []bool{true, false}},
+	}
+	for _, test := range tests {
+		stubs := map[string]*httptest.Server{
+			"default": httptest.NewServer(&pilosaStub{}),
+			"new":     httptest.NewServer(&pilosaStub{}),
+		}
+		if test.failing != "" {
+			stubs[test.failing].Close()
+			stubs[test.failing] = httptest.NewServer(&pilosaStub{fail: 1000})
+		}
+		s := newTestServer(t, stubs["default"].Listener.Addr().String())
+		addTestCluster(t, s, "new", stubs["new"].Listener.Addr().String())
+
+		cmp := s.RunComparison(qs, []string{"default", "new"}, "interleaved", 2, opts, nil)
+		for _, srv := range stubs {
+			srv.Close()
+		}
+		for i, ct := range cmp.Clusters {
+			if (ct.Speedup != nil) != test.wantSpeedup[i] {
+				t.Errorf("%v: %v has speedup %v, want one: %v", test.name, ct.Cluster, ct.Speedup, test.wantSpeedup[i])
+			}
+			if (ct.Error != "") != (ct.Cluster == test.failing) {
+				t.Errorf("%v: %v has error %q", test.name, ct.Cluster, ct.Error)
+			}
+		}
+		if speedup := cmp.Clusters[0].Speedup; speedup != nil && *speedup != 1 {
+			t.Errorf("%v: baseline has speedup %v, want 1", test.name, *speedup)
+		}
+	}
+}
+
+func TestHandleCompareClusters(t *testing.T) {
+	s := newTestServer(t, "localhost:0")
+	addTestCluster(t, s, "new", "localhost:0")
+	router, err := s.routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clusters string
+		want     int
+	}{
+		{"default,default", http.StatusBadRequest},
+		{"default,new,new", http.StatusBadRequest},
+		{"default,other", http.StatusBadRequest},
+		{"default", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/compare/1.1?clusters="+test.clusters, nil))
+		if w.Code != test.want {
+			t.Errorf("clusters=%v: got status %d, want %d: %s", test.clusters, w.Code, test.want, w.Body)
+		}
+	}
+}
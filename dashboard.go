@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rakyll/statik/fs"
+
+	_ "github.com/pilosa/demo-ssb/statik" // dashboard assets, generated from ./static
+)
+
+// queryNames lists every QuerySet, the "all" suite followed by the "reg" variants.
+func queryNames() []string {
+	return append(append([]string{}, suites["all"]...), suites["reg"]...)
+}
+
+// HandleQueries lists the available query sets and suites.
+func (s *Server) HandleQueries(w http.ResponseWriter, r *http.Request) {
+	resp := QueryList{Suites: suites}
+	for _, name := range queryNames() {
+		qs := getQuerySet(name)
+		resp.Queries = append(resp.Queries, QueryInfo{
+			Name:       qs.Name,
+			Labels:     qs.Labels(),
+			ArgSets:    qs.ArgSets,
+			Iterations: qs.iterations,
+			PQL:        qs.Format,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf("writing queries to responsewriter: %v", err)
+	}
+}
+
+// addDashboard serves the embedded dashboard at /. It must be added after the API
+// routes, since it matches every path.
+func addDashboard(router *mux.Router) error {
+	statikFS, err := fs.New()
+	if err != nil {
+		return fmt.Errorf("loading dashboard assets: %v", err)
+	}
+	router.PathPrefix("/").Handler(http.FileServer(statikFS)).Methods("GET")
+	return nil
+}
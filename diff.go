@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// DiffRows joins a and b on their inputs and reports the groups only in a
+// (missing), only in b (extra), and in both with different values (changed),
+// each sorted by inputs.
+func DiffRows(a, b []ResultRow) RunDiff {
+	d := RunDiff{Missing: []ResultRow{}, Extra: []ResultRow{}, Changed: []ValueDiff{}}
+	inB := make(map[string]ResultRow, len(b))
+	for _, row := range b {
+		inB[row.Key()] = row
+	}
+	inA := make(map[string]bool, len(a))
+	for _, row := range a {
+		inA[row.Key()] = true
+		other, ok := inB[row.Key()]
+		if !ok {
+			d.Missing = append(d.Missing, row)
+			continue
+		}
+		d.Summary.Common++
+		if other.Value != row.Value {
+			d.Changed = append(d.Changed, ValueDiff{Inputs: row.Inputs, A: row.Value, B: other.Value})
+		}
+	}
+	for _, row := range b {
+		if !inA[row.Key()] {
+			d.Extra = append(d.Extra, row)
+		}
+	}
+	sortRows(d.Missing)
+	sortRows(d.Extra)
+	sort.Slice(d.Changed, func(i, j int) bool { return lessInputs(d.Changed[i].Inputs, d.Changed[j].Inputs) })
+	d.Summary.Missing = len(d.Missing)
+	d.Summary.Extra = len(d.Extra)
+	d.Summary.Changed = len(d.Changed)
+	d.Summary.Equal = d.Summary.Missing == 0 && d.Summary.Extra == 0 && d.Summary.Changed == 0
+	return d
+}
+
+// errDiffQueries is returned when diffing runs of different query sets.
+type errDiffQueries struct {
+	a, b string
+}
+
+func (e errDiffQueries) Error() string {
+	return fmt.Sprintf("runs are of different queries: %v and %v", e.a, e.b)
+}
+
+// Diff compares the result rows of the stored runs a and b.
+func (st *Store) Diff(a, b string) (RunDiff, error) {
+	runA, err := st.Run(a)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("%v: %v", a, err)
+	}
+	runB, err := st.Run(b)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("%v: %v", b, err)
+	}
+	if runA.Result.Name != runB.Result.Name {
+		return RunDiff{}, errDiffQueries{runA.Result.Name, runB.Result.Name}
+	}
+	rowsA, err := st.Rows(a)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("%v: %v", a, err)
+	}
+	rowsB, err := st.Rows(b)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("%v: %v", b, err)
+	}
+	d := DiffRows(rowsA, rowsB)
+	d.A, d.B, d.Name = a, b, runA.Result.Name
+	qs := getQuerySet(d.Name)
+	d.Labels = qs.Labels()
+	return d, nil
+}
+
+// HandleRunDiff compares the result rows of two stored runs, e.g.
+// /runs/2.1-1510000000/diff/2.1-1510003600
+func (s *Server) HandleRunDiff(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	vars := mux.Vars(r)
+	for _, id := range []string{vars["id"], vars["other"]} {
+		if _, err := s.store.Run(id); err != nil {
+			writeError(w, storeError(id, err))
+			return
+		}
+	}
+	d, err := s.store.Diff(vars["id"], vars["other"])
+	if _, ok := err.(errDiffQueries); ok {
+		writeError(w, invalidParameter(err))
+		return
+	} else if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		fmt.Printf("writing diff to responsewriter: %v", err)
+	}
+}
+
+// diffCommand implements `main diff <run a> <run b>`, printing the diff of two
+// stored runs as JSON, through the server at api if it is running. Like diff(1),
+// it returns 0 if the results are equal, 1 if they differ and 2 on error.
+func diffCommand(storePath string, api *client.Client, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: main [--store path] [--server host:port] diff <run a> <run b>")
+		return 2
+	}
+	var d interface{}
+	var equal bool
+	if api != nil {
+		rd, err := api.Diff(args[0], args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		d, equal = rd, rd.Summary.Equal
+	} else {
+		store, err := OpenStore(storePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer store.Close()
+		rd, err := store.Diff(args[0], args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		d, equal = rd, rd.Summary.Equal
+	}
+	if err := printJSON(d); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !equal {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	row := func(value int, inputs ...int) ResultRow { return ResultRow{Inputs: inputs, Value: value} }
+	tests := []struct {
+		name        string
+		a, b        []ResultRow
+		wantMissing []ResultRow
+		wantExtra   []ResultRow
+		wantChanged []ValueDiff
+		wantCommon  int
+	}{
+		{"empty", nil, nil, nil, nil, nil, 0},
+		{"equal",
+			[]ResultRow{row(10, 1, 1993), row(20, 2, 1993)},
+			[]ResultRow{row(20, 2, 1993), row(10, 1, 1993)},
+			nil, nil, nil, 2},
+		{"missing and extra",
+			[]ResultRow{row(10, 1, 1993), row(30, 3, 1993), row(20, 2, 1993)},
+			[]ResultRow{row(10, 1, 1993), row(40, 4, 1993)},
+			[]ResultRow{row(20, 2, 1993), row(30, 3, 1993)},
+			[]ResultRow{row(40, 4, 1993)},
+			nil, 1},
+		{"changed",
+			[]ResultRow{row(10, 2, 1994), row(20, 1, 1994), row(30, 1, 1993)},
+			[]ResultRow{row(11, 2, 1994), row(20, 1, 1994), row(31, 1, 1993)},
+			nil, nil,
+			[]ValueDiff{{Inputs: []int{1, 1993}, A: 30, B: 31}, {Inputs: []int{2, 1994}, A: 10, B: 11}},
+			3},
+		{"inputs differ in length",
+			[]ResultRow{row(10, 1, 2)},
+			[]ResultRow{row(10, 1, 2, 3), row(10, 12)},
+			[]ResultRow{row(10, 1, 2)},
+			[]ResultRow{row(10, 1, 2, 3), row(10, 12)},
+			nil, 0},
+		{"a empty",
+			nil,
+			[]ResultRow{row(5, 7)},
+			nil, []ResultRow{row(5, 7)}, nil, 0},
+	}
+	for _, test := range tests {
+		d := DiffRows(test.a, test.b)
+		if test.wantMissing == nil {
+			test.wantMissing = []ResultRow{}
+		}
+		if test.wantExtra == nil {
+			test.wantExtra = []ResultRow{}
+		}
+		if test.wantChanged == nil {
+			test.wantChanged = []ValueDiff{}
+		}
+		if !reflect.DeepEqual(d.Missing, test.wantMissing) {
+			t.Errorf("%v: got missing %v, want %v", test.name, d.Missing, test.wantMissing)
+		}
+		if !reflect.DeepEqual(d.Extra, test.wantExtra) {
+			t.Errorf("%v: got extra %v, want %v", test.name, d.Extra, test.wantExtra)
+		}
+		if !reflect.DeepEqual(d.Changed, test.wantChanged) {
+			t.Errorf("%v: got changed %v, want %v", test.name, d.Changed, test.wantChanged)
+		}
+		want := DiffSummary{
+			Common:  test.wantCommon,
+			Missing: len(test.wantMissing),
+			Extra:   len(test.wantExtra),
+			Changed: len(test.wantChanged),
+			Equal:   len(test.wantMissing)+len(test.wantExtra)+len(test.wantChanged) == 0,
+		}
+		if d.Summary != want {
+			t.Errorf("%v: got summary %+v, want %+v", test.name, d.Summary, want)
+		}
+	}
+}
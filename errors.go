@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// Error codes of APIError.
+const (
+	codeUnknownQuery      = client.CodeUnknownQuery
+	codeUnknownQueryType  = client.CodeUnknownQueryType
+	codeInvalidParameter  = client.CodeInvalidParameter
+	codeBusy              = client.CodeBusy
+	codePilosaError       = client.CodePilosaError
+	codePilosaUnreachable = client.CodePilosaUnreachable
+	codePilosaTimeout     = client.CodePilosaTimeout
+	codeRunFailed         = client.CodeRunFailed
+	codeUnknownSuite      = client.CodeUnknownSuite
+	codeRunNotFound       = client.CodeRunNotFound
+	codeNoBaseline        = client.CodeNoBaseline
+	codeHistoryDisabled   = client.CodeHistoryDisabled
+	codeInternal          = client.CodeInternal
+)
+
+// errHistoryDisabled is the error of the run history endpoints without a store.
+var errHistoryDisabled = APIError{
+	Status:  http.StatusServiceUnavailable,
+	Code:    codeHistoryDisabled,
+	Message: "run history is disabled; start with --store",
+}
+
+// invalidParameter is the error of a request with a missing or malformed parameter.
+func invalidParameter(err error) APIError {
+	return APIError{Status: http.StatusBadRequest, Code: codeInvalidParameter, Message: err.Error()}
+}
+
+// internalError is the error of a request that failed in the harness itself.
+func internalError(err error) APIError {
+	return APIError{Status: http.StatusInternalServerError, Code: codeInternal, Message: err.Error()}
+}
+
+// storeError is the error of a request for the stored run id that failed with err.
+func storeError(id string, err error) APIError {
+	if err == ErrRunNotFound {
+		return APIError{Status: http.StatusNotFound, Code: codeRunNotFound, Message: fmt.Sprintf("%v: %v", id, err)}
+	}
+	return internalError(err)
+}
+
+// writeError writes e as a JSON error response.
+func writeError(w http.ResponseWriter, e APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	if err := json.NewEncoder(w).Encode(client.ErrorResponse{Error: e}); err != nil {
+		fmt.Printf("writing error: %v to responsewriter: %v", e, err)
+	}
+}
+
+// pilosaError marks an error from Pilosa or the connection to it, as opposed to
+// one in the harness itself.
+type pilosaError struct {
+	error
+}
+
+// runError describes why a failed run failed.
+func runError(bench benchmarkRun) APIError {
+	e := APIError{
+		Status:  http.StatusInternalServerError,
+		Code:    codeRunFailed,
+		Message: fmt.Sprintf("run of %v failed", bench.Name),
+	}
+	if bench.err == nil {
+		return e
+	}
+	e.Message += ": " + bench.err.Error()
+	perr, ok := bench.err.(pilosaError)
+	if !ok {
+		return e
+	}
+	e.Pilosa = perr.Error()
+	switch {
+	case perr.error == errBatchTimeout || errorType(perr.error) == "timeout":
+		e.Status, e.Code = http.StatusGatewayTimeout, codePilosaTimeout
+	case errorType(perr.error) == "connection":
+		e.Status, e.Code = http.StatusBadGateway, codePilosaUnreachable
+	default:
+		e.Status, e.Code = http.StatusBadGateway, codePilosaError
+	}
+	return e
+}
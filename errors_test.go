@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// checkError checks that w holds a JSON error response with the given status and code.
+func checkError(t *testing.T, name string, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("%v: got status %d, want %d: %s", name, w.Code, status, w.Body)
+		return
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%v: got content type %q, want application/json", name, ct)
+	}
+	var resp client.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Errorf("%v: decoding error response: %v", name, err)
+		return
+	}
+	if resp.Error.Status != status || resp.Error.Code != code {
+		t.Errorf("%v: got error %+v, want status %d and code %v", name, resp.Error, status, code)
+	}
+}
+
+func TestHandleQueryErrors(t *testing.T) {
+	// An address nothing listens on.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		stub    *pilosaStub // nil for an unreachable Pilosa
+		setup   func(s *Server)
+		status  int
+		code    string // of the error response, or of each result of a 200
+		results int
+		partial bool
+	}{
+		{"unknown query type", "/foo/1.1", &pilosaStub{}, nil, http.StatusNotFound, codeUnknownQueryType, 0, false},
+		{"unknown query", "/query/9.9", &pilosaStub{}, nil, http.StatusNotFound, codeUnknownQuery, 0, false},
+		{"invalid parameter", "/query/1.1?concurrency=0", &pilosaStub{}, nil, http.StatusBadRequest, codeInvalidParameter, 0, false},
+		{"invalid wait", "/query/1.1?wait=maybe", &pilosaStub{}, nil, http.StatusBadRequest, codeInvalidParameter, 0, false},
+		{"busy", "/query/1.1?wait=false", &pilosaStub{}, func(s *Server) { s.queue.TryAcquire("/query/1.2") }, http.StatusConflict, codeBusy, 0, false},
+		{"pilosa error", "/query/1.1", &pilosaStub{fail: 1000}, nil, http.StatusBadGateway, codePilosaError, 0, false},
+		{"pilosa unreachable", "/query/1.1", nil, nil, http.StatusBadGateway, codePilosaUnreachable, 0, false},
+		{"setup timeout", "/query/4.1rb", &pilosaStub{delay: time.Second}, func(s *Server) { s.batchTimeout = 20 * time.Millisecond }, http.StatusGatewayTimeout, codePilosaTimeout, 0, false},
+		{"no results directory", "/query/1.1", &pilosaStub{}, func(s *Server) { ioutil.WriteFile("results", nil, 0666) }, http.StatusInternalServerError, codeRunFailed, 0, false},
+		{"complete", "/query/1.1", &pilosaStub{}, nil, http.StatusOK, "", 1, false},
+		{"partial", "/query/1.1", &pilosaStub{fail: 1}, func(s *Server) { s.errorPolicy.Continue = true }, http.StatusOK, "", 1, true},
+		{"grid all failed", "/grid/1.1", &pilosaStub{fail: 1000}, nil, http.StatusBadGateway, codePilosaError, 0, false},
+	}
+	for _, test := range tests {
+		addr := closed
+		if test.stub != nil {
+			srv := httptest.NewServer(test.stub)
+			defer srv.Close()
+			addr = srv.Listener.Addr().String()
+		}
+		s := newTestServer(t, addr)
+		s.concurrency, s.batchSize, s.order, s.balance, s.format = 1, 1, "sequential", "round-robin", "txt"
+		s.gate = RegressionPolicy{Metric: "mean"}
+		if test.setup != nil {
+			test.setup(s)
+		}
+		router, err := s.routes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if test.status != http.StatusOK {
+			checkError(t, test.name, w, test.status, test.code)
+			continue
+		}
+		var results []BenchmarkResult
+		if w.Code != http.StatusOK {
+			t.Errorf("%v: got status %d, want 200: %s", test.name, w.Code, w.Body)
+		} else if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+			t.Errorf("%v: decoding results: %v", test.name, err)
+		} else if len(results) != test.results {
+			t.Errorf("%v: got %d results, want %d", test.name, len(results), test.results)
+		}
+		for _, res := range results {
+			if res.Code != test.code || res.Partial != test.partial {
+				t.Errorf("%v: got code %q and partial %v, want %q and %v", test.name, res.Code, res.Partial, test.code, test.partial)
+			}
+		}
+	}
+}
+
+// TestHandleGridPartial checks that a grid keeps its completed runs when others fail.
+func TestHandleGridPartial(t *testing.T) {
+	srv := httptest.NewServer(&pilosaStub{fail: 1})
+	defer srv.Close()
+	s := newTestServer(t, srv.Listener.Addr().String())
+	s.order, s.balance, s.format = "sequential", "round-robin", "txt"
+	s.gate = RegressionPolicy{Metric: "mean"}
+	router, err := s.routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/grid/1.1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	var results []BenchmarkResult
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 9 {
+		t.Fatalf("got %d results, want 9", len(results))
+	}
+	if results[0].Seconds != -1 || results[0].Code != codePilosaError {
+		t.Errorf("got first run %+v, want a failure with code %v", results[0], codePilosaError)
+	}
+	for _, res := range results[1:] {
+		if res.Seconds < 0 || res.Code != "" {
+			t.Errorf("got run %+v, want a success", res)
+		}
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	run := StoredRun{ID: "1.1-a", Time: time.Now(), Result: BenchmarkResult{RunID: "1.1-a", Name: "1.1", Seconds: 1}}
+	if err := store.SaveRun(run, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		store  *Store
+		status int
+		code   string
+	}{
+		{"unknown suite", "GET", "/suite/nope", store, http.StatusNotFound, codeUnknownSuite},
+		{"compare unknown query", "GET", "/compare/9.9", store, http.StatusNotFound, codeUnknownQuery},
+		{"compare invalid parameter", "GET", "/compare/1.1?clusters=default", store, http.StatusBadRequest, codeInvalidParameter},
+		{"workload invalid parameter", "GET", "/workload?mix=nonsense", store, http.StatusBadRequest, codeInvalidParameter},
+		{"runs invalid parameter", "GET", "/runs?limit=-1", store, http.StatusBadRequest, codeInvalidParameter},
+		{"run not found", "GET", "/runs/1.1-z", store, http.StatusNotFound, codeRunNotFound},
+		{"results not found", "GET", "/runs/1.1-z/results", store, http.StatusNotFound, codeRunNotFound},
+		{"diff not found", "GET", "/runs/1.1-a/diff/1.1-z", store, http.StatusNotFound, codeRunNotFound},
+		{"baseline not found", "POST", "/runs/1.1-z/baseline", store, http.StatusNotFound, codeRunNotFound},
+		{"gate not found", "GET", "/runs/1.1-z/gate", store, http.StatusNotFound, codeRunNotFound},
+		{"gate no baseline", "GET", "/runs/1.1-a/gate", store, http.StatusNotFound, codeNoBaseline},
+		{"gate invalid parameter", "GET", "/gate?metric=median", store, http.StatusBadRequest, codeInvalidParameter},
+		{"report not found", "GET", "/report?runs=1.1-z", store, http.StatusNotFound, codeRunNotFound},
+		{"runs disabled", "GET", "/runs", nil, http.StatusServiceUnavailable, codeHistoryDisabled},
+		{"baselines disabled", "GET", "/baselines", nil, http.StatusServiceUnavailable, codeHistoryDisabled},
+		{"gate disabled", "GET", "/gate", nil, http.StatusServiceUnavailable, codeHistoryDisabled},
+		{"report disabled", "GET", "/report", nil, http.StatusServiceUnavailable, codeHistoryDisabled},
+	}
+	for _, test := range tests {
+		s := newTestServer(t, "localhost:0")
+		s.gate = RegressionPolicy{Metric: "mean", Tolerance: 0.1}
+		s.store = test.store
+		router, err := s.routes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		checkError(t, test.name, w, test.status, test.code)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pilosa/demo-ssb/ssbref"
+)
+
+// lineordersPerSF is the number of lineorder rows dbgen generates per unit of scale factor.
+const lineordersPerSF = 6000000
+
+// estimateScaleFactor rounds a lineorder count to the nearest scale factor.
+func estimateScaleFactor(lineorders uint64) int {
+	return int(math.Max(1, math.Floor(float64(lineorders)/lineordersPerSF+0.5)))
+}
+
+// scaleFactor returns the SSB scale factor of the data in c, estimated from its
+// lineorder count unless the server was started with --scale-factor.
+func (s *Server) scaleFactor(c *Cluster) int {
+	if s.sf > 0 {
+		return s.sf
+	}
+	return estimateScaleFactor(c.NumLineOrders)
+}
+
+// goldenPath returns <dir>/sf<N>/<query>.csv.
+func goldenPath(dir string, sf int, query string) string {
+	return filepath.Join(dir, fmt.Sprintf("sf%d", sf), query+".csv")
+}
+
+// readGolden reads expected result rows from a CSV file with a header row naming
+// the inputs, followed by "value", as written by /runs/{id}/results?format=csv.
+func readGolden(fname string) ([]ResultRow, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %v: %v", fname, err)
+	}
+	var rows []ResultRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %v: %v", fname, err)
+		}
+		row := ResultRow{Inputs: make([]int, len(header)-1)}
+		for n, field := range record {
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("reading %v: invalid %v: %q", fname, header[n], field)
+			}
+			if n < len(row.Inputs) {
+				row.Inputs[n] = v
+			} else {
+				row.Value = v
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// writeGolden writes expected result rows for a query, sorted by input.
+func writeGolden(fname string, labels []string, rows []ResultRow) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	sortRows(rows)
+	if err := writeRowsCSV(f, labels, rows); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// goldenCommand implements `main golden <dbgen dir> [query...]`, computing the
+// answers to the named queries, or every query with a reference implementation,
+// from dbgen .tbl files and writing them as golden answers. Every input
+// combination of a QuerySet is written, with 0 for groups without lineorders.
+func goldenCommand(goldenDir string, sf int, args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: main [--golden dir] [--scale-factor N] golden <dbgen dir> [query...]")
+		return 2
+	}
+	dir, queries := args[0], args[1:]
+	if len(queries) == 0 {
+		for name := range ssbref.Queries {
+			queries = append(queries, name)
+		}
+		sort.Strings(queries)
+	}
+	for _, name := range queries {
+		if qs := getQuerySet(name); qs.Name == "" {
+			fmt.Fprintf(os.Stderr, "unknown query: %v\n", name)
+			return 2
+		}
+	}
+
+	answers, err := ssbref.Compute(dir, queries...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if sf <= 0 {
+		sf = estimateScaleFactor(uint64(answers.LineOrders))
+	}
+	fmt.Printf("%d lineorders, scale factor %d\n", answers.LineOrders, sf)
+	for _, name := range queries {
+		qs := getQuerySet(name)
+		rows := make([]ResultRow, qs.iterations)
+		for n := range rows {
+			inputs := qs.QueryResultN(n).intInputs()
+			rows[n] = ResultRow{Inputs: inputs, Value: int(answers.Value(name, inputs))}
+		}
+		fname := goldenPath(goldenDir, sf, name)
+		if err := writeGolden(fname, qs.Labels(), rows); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		fmt.Printf("wrote %v\n", fname)
+	}
+	return 0
+}
+
+// verify compares the result rows of bench against the golden answers for its
+// query at the scale factor of the cluster it ran against.
+func (s *Server) verify(bench benchmarkRun) *Verification {
+	c, ok := s.Clusters[bench.Cluster]
+	if !ok {
+		c = s.Cluster
+	}
+	v := &Verification{ScaleFactor: s.scaleFactor(c)}
+	v.Golden = goldenPath(s.goldenDir, v.ScaleFactor, bench.Name)
+	golden, err := readGolden(v.Golden)
+	if err != nil {
+		v.Error = err.Error()
+		fmt.Printf("verifying %v: %v\n", bench.Name, err)
+		return v
+	}
+	d := DiffRows(golden, bench.rows)
+	v.Summary, v.Missing, v.Extra, v.Mismatches = d.Summary, d.Missing, d.Extra, d.Changed
+	v.Pass = d.Summary.Equal
+	if !v.Pass {
+		fmt.Printf("%v does not match %v: %d missing, %d extra, %d wrong\n", bench.Name, v.Golden, d.Summary.Missing, d.Summary.Extra, d.Summary.Changed)
+	}
+	return v
+}
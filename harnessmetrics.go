@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// harnessMetrics are the harness's own Prometheus metrics, served at /metrics.
+// They are fed by the workers in runRawSumBatchQuery and RunWorkload, so they
+// cover every run whether it came from a query, suite, compare or workload request.
+type harnessMetrics struct {
+	registry *prometheus.Registry
+
+	queries  *prometheus.CounterVec   // queries sent, by query set
+	batches  *prometheus.CounterVec   // batch requests sent, by query set and node
+	errors   *prometheus.CounterVec   // failed batches, by query set and kind
+	retries  *prometheus.CounterVec   // batch retries, by query set
+	latency  *prometheus.HistogramVec // batch request latency, by query set
+	inFlight prometheus.Gauge         // requests to Pilosa awaiting a response
+}
+
+func newHarnessMetrics(s *Server) *harnessMetrics {
+	m := &harnessMetrics{
+		registry: prometheus.NewRegistry(),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ssb",
+			Name:      "queries_sent_total",
+			Help:      "Queries sent to Pilosa.",
+		}, []string{"query"}),
+		batches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ssb",
+			Name:      "batches_sent_total",
+			Help:      "Batch requests sent to Pilosa, excluding retries.",
+		}, []string{"query", "node"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ssb",
+			Name:      "batch_errors_total",
+			Help:      "Batches that failed after retrying (kind=error) or timed out (kind=timeout).",
+		}, []string{"query", "kind"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ssb",
+			Name:      "batch_retries_total",
+			Help:      "Batch requests retried after an error.",
+		}, []string{"query"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "ssb",
+			Name:      "batch_latency_seconds",
+			Help:      "Latency of batch requests, including retries.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"query"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "ssb",
+			Name:      "requests_in_flight",
+			Help:      "Requests to Pilosa awaiting a response.",
+		}),
+	}
+	// Run state is read from the queue at scrape time. s.queue is replaced after
+	// NewServer when --parallel-runs is set, so it is looked up on every call.
+	queueGauge := func(name, help string, f func(QueueState) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "ssb",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return f(s.queue.State()) })
+	}
+	m.registry.MustRegister(
+		m.queries, m.batches, m.errors, m.retries, m.latency, m.inFlight,
+		queueGauge("runs_running", "Benchmark runs in progress.", func(q QueueState) float64 {
+			return float64(len(q.Running))
+		}),
+		queueGauge("runs_waiting", "Benchmark runs waiting in the queue.", func(q QueueState) float64 {
+			return float64(len(q.Waiting))
+		}),
+		queueGauge("run_queries_done", "Completed queries of the query sets now running.", func(q QueueState) float64 {
+			var done int
+			for _, r := range q.Running {
+				done += r.Done
+			}
+			return float64(done)
+		}),
+		queueGauge("run_queries_total", "Total queries of the query sets now running.", func(q QueueState) float64 {
+			var total int
+			for _, r := range q.Running {
+				total += r.Total
+			}
+			return float64(total)
+		}),
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *harnessMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeBatch records one batch request of size queries for query set name.
+func (m *harnessMetrics) observeBatch(name, node string, size, retries int, latency time.Duration, err error) {
+	m.queries.WithLabelValues(name).Add(float64(size))
+	m.batches.WithLabelValues(name, node).Inc()
+	m.retries.WithLabelValues(name).Add(float64(retries))
+	m.latency.WithLabelValues(name).Observe(latency.Seconds())
+	switch {
+	case err == errBatchTimeout:
+		m.errors.WithLabelValues(name, "timeout").Inc()
+	case err != nil:
+		m.errors.WithLabelValues(name, "error").Inc()
+	}
+}
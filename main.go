@@ -5,12 +5,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
-	pilosa "github.com/pilosa/go-pilosa"
+	"github.com/pilosa/demo-ssb/client"
 	// ssb "github.com/pilosa/pdk/ssb"
 	"github.com/spf13/pflag"
 )
@@ -63,120 +66,223 @@ func main() {
 	concurrency := pflag.IntP("concurrency", "c", 32, "number of queries to execute in parallel")
 	batchSize := pflag.IntP("batchsize", "b", 1, "number of queries to combine into a single batch request")
 	index := pflag.StringP("index", "i", "ssb", "pilosa index")
+	retries := pflag.Int("retries", 0, "number of times to retry a failed batch query")
+	backoff := pflag.Duration("backoff", 100*time.Millisecond, "delay before the first retry, doubled on each attempt")
+	continueOnError := pflag.Bool("continue-on-error", false, "count failed queries and keep running instead of aborting")
+	batchTimeout := pflag.Duration("batch-timeout", 0, "maximum time to wait for a single batch query (0 for no limit)")
+	runTimeout := pflag.Duration("run-timeout", 0, "maximum duration of a single benchmark run (0 for no limit)")
+	order := pflag.String("order", "sequential", "default query order: sequential, random or reverse")
+	precompute := pflag.Bool("precompute", false, "generate all query strings before starting the timer")
+	format := pflag.String("format", "txt", "results file format: txt, csv, jsonl or arrow")
+	nodes := pflag.StringSlice("nodes", nil, "additional host:port addresses of nodes in the pilosa cluster to spread batches across")
+	discoverNodes := pflag.Bool("discover-nodes", false, "spread batches across all nodes listed by each cluster's /status endpoint")
+	balance := pflag.String("balance", "round-robin", "default way to spread batches across nodes: round-robin, random or least-loaded")
+	serverMetrics := pflag.Bool("server-metrics", false, "snapshot each node's /debug/vars before and after every run and report the change")
+	clusters := pflag.StringSlice("cluster", nil, "additional pilosa clusters to compare against, as name=host:port")
+	storePath := pflag.String("store", "results/history.db", "BoltDB file recording every benchmark run (empty to disable)")
+	regressionMetric := pflag.String("regression-metric", "mean", "batch latency compared against baselines: mean or p99")
+	regressionTolerance := pflag.Float64("regression-tolerance", 0.1, "slowdown relative to the baseline tolerated before a run fails the regression gate")
+	verify := pflag.Bool("verify", false, "compare the result rows of every run against the golden answers")
+	goldenDir := pflag.String("golden", "golden", "directory of golden answers, as <dir>/sf<N>/<query>.csv")
+	scaleFactor := pflag.Int("scale-factor", 0, "SSB scale factor of the data, for finding golden answers (0 to estimate it from the lineorder count)")
+	parallelRuns := pflag.Bool("parallel-runs", false, "allow benchmark requests to run at the same time instead of queueing them")
+	serverAddr := pflag.String("server", "localhost:8000", "demo server that the diff, gate and report commands use instead of the store while it is running (empty to always open the store)")
 	pflag.Parse()
 
+	gate, err := parseRegressionPolicy(RegressionPolicy{}, *regressionMetric, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	gate.Tolerance = *regressionTolerance
+	switch pflag.Arg(0) {
+	case "diff":
+		os.Exit(diffCommand(*storePath, serverClient(*serverAddr), pflag.Args()[1:]))
+	case "gate":
+		os.Exit(gateCommand(*storePath, serverClient(*serverAddr), gate, pflag.Args()[1:]))
+	case "golden":
+		os.Exit(goldenCommand(*goldenDir, *scaleFactor, pflag.Args()[1:]))
+	case "report":
+		os.Exit(reportCommand(*storePath, serverClient(*serverAddr), gate, pflag.Args()[1:]))
+	case "openapi":
+		os.Exit(openAPICommand())
+	}
+
 	server, err := NewServer(*pilosaAddr, *index)
 	if err != nil {
 		log.Fatalf("getting new server: %v", err)
 	}
 	server.concurrency = *concurrency
 	server.batchSize = *batchSize
+	server.precompute = *precompute
+	server.format = *format
+	server.gate = gate
+	server.verifyRuns = *verify
+	server.goldenDir = *goldenDir
+	server.sf = *scaleFactor
+	server.order = *order
+	server.balance = *balance
+	server.serverMetrics = *serverMetrics
+	server.errorPolicy = ErrorPolicy{
+		Retries:  *retries,
+		Backoff:  *backoff,
+		Continue: *continueOnError,
+	}
+	server.batchTimeout = *batchTimeout
+	server.runTimeout = *runTimeout
+	server.queue = NewRunQueue(!*parallelRuns)
+	if *storePath != "" {
+		if err := os.MkdirAll(filepath.Dir(*storePath), 0700); err != nil {
+			log.Fatalf("creating store directory: %v", err)
+		}
+		store, err := OpenStore(*storePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.store = store
+	}
+	for _, c := range *clusters {
+		fields := strings.SplitN(c, "=", 2)
+		if len(fields) != 2 {
+			log.Fatalf("invalid cluster %q, expected name=host:port", c)
+		}
+		if err := server.AddCluster(fields[0], fields[1]); err != nil {
+			log.Fatalf("adding cluster: %v", err)
+		}
+	}
+	if err := server.Cluster.AddNodes(*nodes); err != nil {
+		log.Fatalf("adding nodes: %v", err)
+	}
+	if *discoverNodes {
+		for _, c := range server.Clusters {
+			if err := c.DiscoverNodes(); err != nil {
+				log.Fatalf("discovering nodes of cluster %v: %v", c.Name, err)
+			}
+		}
+	}
 	fmt.Printf("Pilosa: %s\nIndex: %s\n", *pilosaAddr, *index)
-	fmt.Printf("lineorder count: %d\n", server.NumLineOrders)
+	for _, name := range server.ClusterNames() {
+		c := server.Clusters[name]
+		fmt.Printf("cluster %v (%v) lineorder count: %d\n", c.Name, c.Addr, c.NumLineOrders)
+		for _, node := range c.Nodes {
+			fmt.Printf("  node %v\n", node.Addr)
+		}
+	}
 	server.Serve()
 }
 
+// serverClient returns a client for the demo server at addr if one is running
+// there. The server holds the store open, so commands that read it go through
+// the server's API instead.
+func serverClient(addr string) *client.Client {
+	if addr == "" {
+		return nil
+	}
+	c := client.NewClient(addr)
+	probe := *c
+	probe.HTTPClient = &http.Client{Timeout: time.Second}
+	if _, err := probe.Queue(); err != nil {
+		return nil
+	}
+	return c
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
 type Server struct {
-	pilosaAddr    string
 	Router        *mux.Router
-	Client        *pilosa.Client
-	Index         *pilosa.Index
-	Frames        map[string]*pilosa.Frame
+	*Cluster      // the default cluster
+	Clusters      map[string]*Cluster
 	concurrency   int
 	batchSize     int
-	NumLineOrders uint64
+	precompute    bool
+	order         string
+	balance       string
+	format        string
+	gate          RegressionPolicy
+	verifyRuns    bool
+	goldenDir     string
+	sf            int
+	serverMetrics bool
+	errorPolicy   ErrorPolicy
+	batchTimeout  time.Duration
+	runTimeout    time.Duration
+	queue         *RunQueue
+	store         *Store
+	metrics       *harnessMetrics
 }
 
 func NewServer(pilosaAddr, indexName string) (*Server, error) {
 	server := &Server{
-		Frames:      make(map[string]*pilosa.Frame),
+		Clusters:    make(map[string]*Cluster),
 		concurrency: 1,
+		batchSize:   1,
+		order:       "sequential",
+		balance:     "round-robin",
+		format:      "txt",
+		gate:        RegressionPolicy{Metric: "mean", Tolerance: 0.1},
+		goldenDir:   "golden",
+		queue:       NewRunQueue(true),
 	}
+	server.metrics = newHarnessMetrics(server)
 
-	router := mux.NewRouter()
-	router.HandleFunc("/version", server.HandleVersion).Methods("GET")
-	router.HandleFunc("/{qtype}/{qname}", server.HandleQuery).Methods("GET")
-
-	pilosaURI, err := pilosa.NewURIFromAddress(pilosaAddr)
+	router, err := server.routes()
 	if err != nil {
 		return nil, err
 	}
-	client := pilosa.NewClientWithURI(pilosaURI)
-	index, err := pilosa.NewIndex(indexName, nil)
-	if err != nil {
-		return nil, fmt.Errorf("pilosa.NewIndex: %v", err)
-	}
-	err = client.EnsureIndex(index)
-	if err != nil {
-		return nil, fmt.Errorf("client.EnsureIndex: %v", err)
-	}
-
-	// TODO should be automatic from /schema
-	frames := []string{
-		"lo_quantity", // these frames X each have one field, field_X
-		"lo_quantity_b",
-		"lo_extendedprice",
-		"lo_discount",
-		"lo_discount_b",
-		"lo_revenue",
-		"lo_supplycost",
-		"lo_profit",
-		"lo_revenue_computed",
-		"c_city",
-		"c_nation",
-		"c_region",
-		"s_city",
-		"s_nation",
-		"s_region",
-		"p_mfgr",
-		"p_category",
-		"p_brand1",
-		"lo_year",
-		"lo_month",
-		"lo_weeknum",
-	}
-
-	for _, frameName := range frames {
-		frame, err := index.Frame(frameName, nil)
-		if err != nil {
-			return nil, fmt.Errorf("index.Frame %v: %v", frameName, err)
-		}
-		err = client.EnsureFrame(frame)
-		if err != nil {
-			return nil, fmt.Errorf("client.EnsureFrame %v: %v", frameName, err)
-		}
 
-		server.Frames[frameName] = frame
+	cluster, err := NewCluster("default", pilosaAddr, indexName)
+	if err != nil {
+		return nil, err
 	}
 
 	server.Router = router
-	server.Client = client
-	server.Index = index
-	server.NumLineOrders = server.getLineOrderCount()
+	server.Cluster = cluster
+	server.Clusters[cluster.Name] = cluster
 	return server, nil
 }
 
-func (s *Server) getLineOrderCount() uint64 {
-	var count uint64 = 0
-	for n := 0; n < 5; n++ {
-		q := s.Index.Count(s.Frames["p_mfgr"].Bitmap(uint64(n)))
-		response, err := s.Client.Query(q, nil)
-		if err != nil {
-			fmt.Printf("in getLineOrderCount: %v\n", err)
-			return 666
-		}
-		count += response.Result().Count
+// routes returns the router for the HTTP API and the dashboard. openAPISpec
+// documents the API from it, so every route needs an entry in apiDocs.
+func (s *Server) routes() (*mux.Router, error) {
+	router := mux.NewRouter()
+	router.HandleFunc("/version", s.HandleVersion).Methods("GET")
+	router.HandleFunc("/queue", s.HandleQueue).Methods("GET")
+	router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	router.HandleFunc("/workload", s.HandleWorkload).Methods("GET")
+	router.HandleFunc("/suite", s.HandleSuite).Methods("GET")
+	router.HandleFunc("/suite/{name}", s.HandleSuite).Methods("GET")
+	router.HandleFunc("/compare/{qname}", s.HandleCompare).Methods("GET")
+	router.HandleFunc("/runs", s.HandleRuns).Methods("GET")
+	router.HandleFunc("/runs/{id}", s.HandleRun).Methods("GET")
+	router.HandleFunc("/runs/{id}/results", s.HandleRunResults).Methods("GET")
+	router.HandleFunc("/runs/{id}/diff/{other}", s.HandleRunDiff).Methods("GET")
+	router.HandleFunc("/runs/{id}/baseline", s.HandleSetBaseline).Methods("POST")
+	router.HandleFunc("/runs/{id}/gate", s.HandleGate).Methods("GET")
+	router.HandleFunc("/baselines", s.HandleBaselines).Methods("GET")
+	router.HandleFunc("/gate", s.HandleGate).Methods("GET")
+	router.HandleFunc("/report", s.HandleReport).Methods("GET")
+	router.HandleFunc("/queries", s.HandleQueries).Methods("GET")
+	router.HandleFunc("/openapi.json", s.HandleOpenAPI).Methods("GET")
+	router.HandleFunc("/{qtype}/{qname}", s.HandleQuery).Methods("GET")
+	if err := addDashboard(router); err != nil {
+		return nil, err
 	}
-	return count
+	return router, nil
 }
 
 func (s *Server) HandleVersion(w http.ResponseWriter, r *http.Request) {
-	if err := json.NewEncoder(w).Encode(struct {
-		DemoVersion   string `json:"demoversion"`
-		PilosaVersion string `json:"pilosaversion"`
-	}{
+	pilosaVersion, err := getPilosaVersion(s.Cluster.Addr)
+	if err != nil {
+		log.Printf("getting pilosa version: %v", err)
+	}
+	if err := json.NewEncoder(w).Encode(VersionInfo{
 		DemoVersion:   Version,
-		PilosaVersion: getPilosaVersion(s.pilosaAddr),
+		PilosaVersion: pilosaVersion,
 	}); err != nil {
 		log.Printf("write version response error: %s", err)
 	}
@@ -186,15 +292,6 @@ type versionResponse struct {
 	Version string `json:"version"`
 }
 
-func getPilosaVersion(host string) string {
-	resp, _ := http.Get("http://" + host + "/version")
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	version := new(versionResponse)
-	json.Unmarshal(body, &version)
-	return version.Version
-}
-
 func (s *Server) Serve() {
 	fmt.Println("Demo running at http://127.0.0.1:8000")
 	log.Fatal(http.ListenAndServe(":8000", s.Router))
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+)
+
+// queryHash returns a hex SHA-256 of everything that determines the queries of qs.
+func queryHash(qs QuerySet) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%v", qs.Format, qs.setup, qs.teardown, qs.ArgSets)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getPilosaVersion returns the version reported by the Pilosa node at host.
+func getPilosaVersion(host string) (string, error) {
+	resp, err := metricsClient.Get("http://" + host + "/version")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting /version: %v", resp.Status)
+	}
+	version := new(versionResponse)
+	if err := json.NewDecoder(resp.Body).Decode(version); err != nil {
+		return "", fmt.Errorf("decoding /version: %v", err)
+	}
+	return version.Version, nil
+}
+
+// getSliceCount returns the number of slices in index, from the highest slice
+// reported by the Pilosa node at host.
+func getSliceCount(host, index string) (uint64, error) {
+	resp, err := metricsClient.Get("http://" + host + "/slices/max")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("getting /slices/max: %v", resp.Status)
+	}
+	var maxSlices struct {
+		Standard map[string]uint64 `json:"standard"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&maxSlices); err != nil {
+		return 0, fmt.Errorf("decoding /slices/max: %v", err)
+	}
+	max, ok := maxSlices.Standard[index]
+	if !ok {
+		return 0, fmt.Errorf("index %v not in /slices/max", index)
+	}
+	return max + 1, nil
+}
+
+// runMetadata describes a run of qs against c with opts.
+func (s *Server) runMetadata(c *Cluster, qs QuerySet, opts RunOptions) RunMetadata {
+	hostname, _ := os.Hostname()
+	meta := RunMetadata{
+		DemoVersion: Version,
+		PilosaAddr:  c.Addr,
+		NodeCount:   len(c.Nodes),
+		Index:       c.Index.Name(),
+		Hostname:    hostname,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		NumCPU:      runtime.NumCPU(),
+		QueryHash:   queryHash(qs),
+		PQL:         qs.Format,
+		Setup:       qs.setup,
+		Teardown:    qs.teardown,
+		Options:     opts,
+		Settings: ServerSettings{
+			Retries:         s.errorPolicy.Retries,
+			Backoff:         s.errorPolicy.Backoff.Seconds(),
+			ContinueOnError: s.errorPolicy.Continue,
+			BatchTimeout:    s.batchTimeout.Seconds(),
+			RunTimeout:      s.runTimeout.Seconds(),
+			ServerMetrics:   s.serverMetrics,
+			ParallelRuns:    !s.queue.exclusive,
+		},
+	}
+	for _, node := range c.Nodes {
+		meta.Nodes = append(meta.Nodes, node.Addr)
+	}
+	var err error
+	if meta.PilosaVersion, err = getPilosaVersion(c.Addr); err != nil {
+		meta.Errors = append(meta.Errors, fmt.Sprintf("pilosa version: %v", err))
+	}
+	if meta.Slices, err = getSliceCount(c.Addr, meta.Index); err != nil {
+		meta.Errors = append(meta.Errors, fmt.Sprintf("slice count: %v", err))
+	}
+	return meta
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// balanceModes lists the supported RunOptions.Balance values.
+var balanceModes = []string{"round-robin", "random", "least-loaded"}
+
+// Node is a single Pilosa node that batches can be sent to. Any node can
+// coordinate a query, so spreading batches across nodes shows whether a single
+// coordinator is the bottleneck.
+type Node struct {
+	Addr     string
+	index    string
+	inflight int64 // requests in progress, accessed atomically
+}
+
+func newNode(addr, index string) *Node {
+	return &Node{Addr: addr, index: index}
+}
+
+// queryClient sends benchmark queries to the nodes. Requests are cancelled
+// through their context rather than a client timeout, and each node keeps enough
+// idle connections for every worker of a run.
+var queryClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: 256,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// queryResponse, queryResult and sumCount decode the parts of Pilosa's protobuf
+// QueryResponse that the benchmark queries use; other fields are skipped.
+type queryResponse struct {
+	Err     string         `protobuf:"bytes,1,opt,name=Err,proto3"`
+	Results []*queryResult `protobuf:"bytes,2,rep,name=Results"`
+}
+
+func (m *queryResponse) Reset()         { *m = queryResponse{} }
+func (m *queryResponse) String() string { return proto.CompactTextString(m) }
+func (*queryResponse) ProtoMessage()    {}
+
+type queryResult struct {
+	N        uint64    `protobuf:"varint,2,opt,name=N,proto3"`
+	SumCount *sumCount `protobuf:"bytes,5,opt,name=SumCount"`
+}
+
+func (m *queryResult) Reset()         { *m = queryResult{} }
+func (m *queryResult) String() string { return proto.CompactTextString(m) }
+func (*queryResult) ProtoMessage()    {}
+
+// sum is the result of a Sum query, or 0 for other queries.
+func (m *queryResult) sum() int {
+	if m.SumCount == nil {
+		return 0
+	}
+	return int(m.SumCount.Sum)
+}
+
+type sumCount struct {
+	Sum   int64 `protobuf:"varint,1,opt,name=Sum,proto3"`
+	Count int64 `protobuf:"varint,2,opt,name=Count,proto3"`
+}
+
+func (m *sumCount) Reset()         { *m = sumCount{} }
+func (m *sumCount) String() string { return proto.CompactTextString(m) }
+func (*sumCount) ProtoMessage()    {}
+
+// queryStats is what sending a query took: the HTTP round trip, including reading
+// the response body, and decoding the protobuf response, summed over the attempts
+// of queryWithRetry.
+type queryStats struct {
+	retries int
+	request time.Duration
+	decode  time.Duration
+}
+
+// queryError is an error reported by Pilosa, as opposed to one reaching it.
+type queryError struct {
+	Status  int
+	Message string
+}
+
+func (e *queryError) Error() string { return e.Message }
+
+// query posts raw PQL to the node's index and decodes the protobuf response.
+// Every call is a new request on queryClient, so a failed request doesn't affect
+// the next one. Errors that Pilosa reports are returned as a *queryError.
+func (n *Node) query(ctx context.Context, raw string) (*queryResponse, queryStats, error) {
+	var stats queryStats
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://%v/index/%v/query", n.Addr, n.index), strings.NewReader(raw))
+	if err != nil {
+		return nil, stats, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/x-protobuf")
+	start := time.Now()
+	resp, err := queryClient.Do(req)
+	if err != nil {
+		stats.request = time.Since(start)
+		return nil, stats, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	stats.request = time.Since(start)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	start = time.Now()
+	response := new(queryResponse)
+	decodeErr := proto.Unmarshal(body, response)
+	stats.decode = time.Since(start)
+	switch {
+	case decodeErr == nil && response.Err != "":
+		return nil, stats, &queryError{Status: resp.StatusCode, Message: response.Err}
+	case resp.StatusCode != http.StatusOK:
+		return nil, stats, &queryError{Status: resp.StatusCode, Message: fmt.Sprintf("%v: %s", resp.Status, bytes.TrimSpace(body))}
+	case decodeErr != nil:
+		return nil, stats, &queryError{Status: resp.StatusCode, Message: fmt.Sprintf("decoding response: %v", decodeErr)}
+	}
+	return response, stats, nil
+}
+
+// AddNodes adds nodes to the cluster, skipping nodes it already has under any
+// address; see sameNode.
+func (c *Cluster) AddNodes(addrs []string) error {
+	for _, addr := range addrs {
+		if c.node(addr) != nil {
+			continue
+		}
+		c.Nodes = append(c.Nodes, newNode(addr, c.Index.Name()))
+	}
+	return nil
+}
+
+func (c *Cluster) node(addr string) *Node {
+	for _, n := range c.Nodes {
+		if sameNode(n.Addr, addr) {
+			return n
+		}
+	}
+	return nil
+}
+
+// sameNode reports whether two node addresses refer to the same node: they have
+// the same port, Pilosa's 10101 if none is given, and their hosts resolve to a
+// common IP address. Every address of this machine counts as the same, so that
+// "localhost:10101", "127.0.0.1:10101", ":10101" and the machine's hostname match.
+// Hosts that don't resolve are compared by name.
+func sameNode(a, b string) bool {
+	if a == b {
+		return true
+	}
+	hostsA, portA := resolveNode(a)
+	hostsB, portB := resolveNode(b)
+	if portA != portB {
+		return false
+	}
+	for _, x := range hostsA {
+		for _, y := range hostsB {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveNode returns the IP addresses of the host of addr, with "local" for any
+// address of this machine, and its port.
+func resolveNode(addr string) ([]string, string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "10101"
+	}
+	var ips []net.IP
+	if host == "" {
+		ips = []net.IP{net.IPv4zero}
+	} else if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if ips, err = net.LookupIP(host); err != nil {
+		return []string{strings.ToLower(host)}, port
+	}
+
+	var local []net.IP
+	if ifaddrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range ifaddrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				local = append(local, ipnet.IP)
+			}
+		}
+	}
+	hosts := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		isLocal := ip.IsLoopback() || ip.IsUnspecified()
+		for _, l := range local {
+			isLocal = isLocal || l.Equal(ip)
+		}
+		if isLocal {
+			hosts = append(hosts, "local")
+		} else {
+			hosts = append(hosts, ip.String())
+		}
+	}
+	return hosts, port
+}
+
+// statusResponse covers the node lists of Pilosa's /status endpoint across versions.
+type statusResponse struct {
+	Status struct {
+		Nodes []struct {
+			Host string `json:"Host"`
+		} `json:"Nodes"`
+	} `json:"status"`
+	Nodes []struct {
+		URI struct {
+			Scheme string `json:"scheme"`
+			Host   string `json:"host"`
+			Port   int    `json:"port"`
+		} `json:"uri"`
+	} `json:"nodes"`
+}
+
+// DiscoverNodes adds every node listed by the cluster's /status endpoint.
+func (c *Cluster) DiscoverNodes() error {
+	resp, err := http.Get("http://" + c.Addr + "/status")
+	if err != nil {
+		return fmt.Errorf("getting cluster status: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting cluster status: %v", resp.Status)
+	}
+	status := new(statusResponse)
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return fmt.Errorf("decoding cluster status: %v", err)
+	}
+
+	var addrs []string
+	for _, n := range status.Status.Nodes {
+		addrs = append(addrs, n.Host)
+	}
+	for _, n := range status.Nodes {
+		addrs = append(addrs, fmt.Sprintf("%v:%d", n.URI.Host, n.URI.Port))
+	}
+	for n, addr := range addrs {
+		addrs[n] = strings.TrimPrefix(addr, "http://")
+	}
+	return c.AddNodes(addrs)
+}
+
+// pickNode chooses the node for the next batch according to balance.
+func (c *Cluster) pickNode(balance string) *Node {
+	switch balance {
+	case "random":
+		return c.Nodes[rand.Intn(len(c.Nodes))]
+	case "least-loaded":
+		best := c.Nodes[0]
+		for _, n := range c.Nodes[1:] {
+			if atomic.LoadInt64(&n.inflight) < atomic.LoadInt64(&best.inflight) {
+				best = n
+			}
+		}
+		return best
+	}
+	next := atomic.AddUint64(&c.next, 1) - 1
+	return c.Nodes[next%uint64(len(c.Nodes))]
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSameNode(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"localhost:10101", "localhost:10101", true},
+		{"localhost:10101", "127.0.0.1:10101", true},
+		{"localhost:10101", "localhost:10102", false},
+		{"localhost", "127.0.0.1:10101", true},
+		{":10101", "127.0.0.1:10101", true},
+		{"[::1]:10101", "127.0.0.1:10101", true},
+		{"10.255.0.1:10101", "10.255.0.1:10101", true},
+		{"10.255.0.1:10101", "10.255.0.2:10101", false},
+		{"10.255.0.1:10101", "localhost:10101", false},
+		{"Pilosa0.invalid:10101", "pilosa0.invalid:10101", true},
+		{"pilosa0.invalid:10101", "pilosa1.invalid:10101", false},
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		// Only if the hostname resolves to one of this machine's addresses.
+		if hosts, _ := resolveNode(hostname); len(hosts) > 0 && hosts[0] == "local" {
+			tests = append(tests, struct {
+				a, b string
+				want bool
+			}{hostname + ":10101", "localhost:10101", true})
+		}
+	}
+	for _, test := range tests {
+		if got := sameNode(test.a, test.b); got != test.want {
+			t.Errorf("sameNode(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := sameNode(test.b, test.a); got != test.want {
+			t.Errorf("sameNode(%q, %q) = %v, want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestDiscoverNodes(t *testing.T) {
+	seed := &fakeNode{}
+	srv := httptest.NewServer(seed)
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	// The seed lists itself under its IP address, along with another node.
+	seed.status = fmt.Sprintf(`{"status": {"Nodes": [{"Host": "127.0.0.1:%v"}, {"Host": "10.255.0.1:10101"}]}}`, port)
+
+	s := newTestServer(t, "localhost:"+port)
+	c := s.Cluster
+	if err := c.DiscoverNodes(); err != nil {
+		t.Fatal(err)
+	}
+	var addrs []string
+	for _, n := range c.Nodes {
+		addrs = append(addrs, n.Addr)
+	}
+	if want := []string{"localhost:" + port, "10.255.0.1:10101"}; fmt.Sprint(addrs) != fmt.Sprint(want) {
+		t.Errorf("got nodes %v, want %v", addrs, want)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// apiParam documents a query or path parameter.
+type apiParam struct {
+	Name        string // defaults to the apiParams key
+	Type        string // "integer", "number", "boolean" or "string"
+	Description string
+	Enum        []string
+}
+
+// apiParams documents every parameter used by the API.
+var apiParams = map[string]apiParam{
+	"concurrency": {Type: "integer", Description: "number of concurrent workers"},
+	"batchsize":   {Type: "integer", Description: "queries per batch request"},
+	"precompute":  {Type: "boolean", Description: "build every query and batch body before the timer starts"},
+	"order":       {Type: "string", Description: "order queries are sent in", Enum: queryOrders},
+	"seed":        {Type: "integer", Description: "random seed"},
+	"cluster":     {Type: "string", Description: "name of the cluster to run against"},
+	"balance":     {Type: "string", Description: "how batches are spread across the cluster's nodes", Enum: balanceModes},
+	"format":      {Type: "string", Description: "results file format", Enum: []string{"txt", "csv", "jsonl", "arrow"}},
+	"profile":     {Type: "boolean", Description: "fetch CPU profiles from every node during the run"},
+	"profileheap": {Type: "boolean", Description: "also fetch a heap profile from every node after the run"},
+	"baseline":    {Type: "boolean", Description: "make the run the baseline for its query if it succeeds"},
+	"verify":      {Type: "boolean", Description: "compare the result rows against the golden answers"},
+	"metric":      {Type: "string", Description: "batch latency compared against the baseline", Enum: []string{"mean", "p99"}},
+	"tolerance":   {Type: "number", Description: "allowed slowdown as a fraction of the baseline"},
+
+	"query": {Type: "string", Description: "query name"},
+	"since": {Type: "string", Description: "unix time, RFC3339 time or date"},
+	"until": {Type: "string", Description: "unix time, RFC3339 time or date"},
+	"limit": {Type: "integer", Description: "maximum number of runs, newest first"},
+	"runs":  {Type: "string", Description: "comma-separated run IDs"},
+
+	"rowsformat": {Name: "format", Type: "string", Description: "response format; Accept: text/csv also selects CSV", Enum: []string{"json", "csv"}},
+
+	"mix":     {Type: "string", Description: "weighted query sets, e.g. 40:2.x,30:3.x,30:4.x"},
+	"queries": {Type: "integer", Description: "number of queries to send"},
+
+	"clusters": {Type: "string", Description: "comma-separated clusters to compare, the first being the baseline"},
+	"mode":     {Type: "string", Description: "how rounds are run on each cluster", Enum: []string{"interleaved", "sequential"}},
+	"rounds":   {Type: "integer", Description: "runs per cluster"},
+
+	"wait": {Type: "boolean", Description: "queue behind other runs; if false, respond 409 when busy"},
+
+	// Path parameters.
+	"qtype": {Type: "string", Description: "a single run, or a grid of concurrency 8, 16, 32 by batch size 2, 4, 8", Enum: []string{"query", "grid"}},
+	"qname": {Type: "string", Description: "query name, see /queries"},
+	"name":  {Type: "string", Description: "suite name, see /queries"},
+	"id":    {Type: "string", Description: "run ID"},
+	"other": {Type: "string", Description: "run ID to compare with"},
+}
+
+var (
+	runParams    = []string{"concurrency", "batchsize", "precompute", "order", "seed", "cluster", "balance", "format", "profile", "profileheap", "baseline", "verify", "metric", "tolerance"}
+	filterParams = []string{"query", "cluster", "since", "until", "concurrency", "batchsize", "limit"}
+)
+
+// apiDoc documents a route.
+type apiDoc struct {
+	Method      string
+	Summary     string
+	Params      []string       // query parameters, keys of apiParams
+	Response    interface{}    // a value of the JSON response type, if any
+	ContentType string         // of a non-JSON response
+	Errors      map[int]string // statuses of error responses, and their codes
+}
+
+// queryErrors are the error responses of /{qtype}/{qname}, by their codes.
+var queryErrors = map[int]string{
+	http.StatusBadRequest:          codeInvalidParameter,
+	http.StatusNotFound:            codeUnknownQuery + " or " + codeUnknownQueryType,
+	http.StatusConflict:            codeBusy + ", with wait=false",
+	http.StatusInternalServerError: codeRunFailed + ", in the harness",
+	http.StatusBadGateway:          codePilosaError + " or " + codePilosaUnreachable,
+	http.StatusGatewayTimeout:      codePilosaTimeout,
+}
+
+// historyErrors are the error responses of the run history routes, by their codes.
+var historyErrors = map[int]string{
+	http.StatusBadRequest:          codeInvalidParameter,
+	http.StatusNotFound:            codeRunNotFound,
+	http.StatusInternalServerError: codeInternal,
+	http.StatusServiceUnavailable:  codeHistoryDisabled + ", without --store",
+}
+
+// gateErrors are the error responses of the gate routes, by their codes.
+var gateErrors = map[int]string{
+	http.StatusBadRequest:          codeInvalidParameter,
+	http.StatusNotFound:            codeRunNotFound + ", or " + codeNoBaseline + " for a query without a baseline",
+	http.StatusInternalServerError: codeInternal,
+	http.StatusServiceUnavailable:  codeHistoryDisabled + ", without --store",
+}
+
+// apiDocs documents each route, by path template.
+var apiDocs = map[string]apiDoc{
+	"/version":                {Method: "GET", Summary: "Demo and Pilosa versions", Response: VersionInfo{}},
+	"/queue":                  {Method: "GET", Summary: "Benchmark runs in progress and waiting", Response: QueueState{}},
+	"/metrics":                {Method: "GET", Summary: "Harness metrics in the Prometheus text format", ContentType: "text/plain"},
+	"/workload":               {Method: "GET", Summary: "Run a weighted mix of query sets", Params: []string{"mix", "queries", "seed", "concurrency", "cluster", "balance"}, Response: WorkloadResult{}, Errors: map[int]string{http.StatusBadRequest: codeInvalidParameter}},
+	"/suite":                  {Method: "GET", Summary: "Run the ssb suite", Params: runParams, Response: SuiteResult{}, Errors: map[int]string{http.StatusBadRequest: codeInvalidParameter}},
+	"/suite/{name}":           {Method: "GET", Summary: "Run a suite", Params: runParams, Response: SuiteResult{}, Errors: map[int]string{http.StatusBadRequest: codeInvalidParameter, http.StatusNotFound: codeUnknownSuite}},
+	"/compare/{qname}":        {Method: "GET", Summary: "Run a query on several clusters and compare timings and results", Params: append([]string{"clusters", "mode", "rounds"}, runParams...), Response: Comparison{}, Errors: map[int]string{http.StatusBadRequest: codeInvalidParameter, http.StatusNotFound: codeUnknownQuery}},
+	"/runs":                   {Method: "GET", Summary: "List stored runs", Params: filterParams, Response: []StoredRun{}, Errors: historyErrors},
+	"/runs/{id}":              {Method: "GET", Summary: "A stored run", Response: StoredRun{}, Errors: historyErrors},
+	"/runs/{id}/results":      {Method: "GET", Summary: "Result rows of a stored run, sorted by input", Params: []string{"rowsformat"}, Response: RunRows{}, ContentType: "text/csv", Errors: historyErrors},
+	"/runs/{id}/diff/{other}": {Method: "GET", Summary: "Compare the result rows of two stored runs", Response: RunDiff{}, Errors: historyErrors},
+	"/runs/{id}/baseline":     {Method: "POST", Summary: "Make a stored run the baseline of its query", Response: map[string]string{}, Errors: historyErrors},
+	"/runs/{id}/gate":         {Method: "GET", Summary: "Check a stored run against the baseline of its query", Params: []string{"metric", "tolerance"}, Response: GateResult{}, Errors: gateErrors},
+	"/baselines":              {Method: "GET", Summary: "Baseline run ID of each query", Response: map[string]string{}, Errors: historyErrors},
+	"/gate":                   {Method: "GET", Summary: "Check the given runs, or the latest run of every query with a baseline, against their baselines", Params: []string{"runs", "metric", "tolerance"}, Response: GateResult{}, Errors: gateErrors},
+	"/report":                 {Method: "GET", Summary: "HTML report of the given runs, or of the runs matching the filter", Params: append([]string{"runs", "metric", "tolerance"}, filterParams...), ContentType: "text/html", Errors: historyErrors},
+	"/queries":                {Method: "GET", Summary: "Query sets and suites", Response: QueryList{}},
+	"/openapi.json":           {Method: "GET", Summary: "This API description", ContentType: "application/json"},
+	"/{qtype}/{qname}":        {Method: "GET", Summary: "Run a query", Params: append([]string{"wait"}, runParams...), Response: []BenchmarkResult{}, Errors: queryErrors},
+	"/":                       {Method: "GET", Summary: "Web dashboard", ContentType: "text/html"},
+}
+
+var pathParam = regexp.MustCompile(`{(\w+)}`)
+
+// openAPISpec returns an OpenAPI 3 description of the routes of router, built
+// from apiDocs. It fails if a route is not documented.
+func openAPISpec(router *mux.Router) (map[string]interface{}, error) {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		doc, ok := apiDocs[path]
+		if !ok {
+			return fmt.Errorf("route %v is not in apiDocs", path)
+		}
+		var params []interface{}
+		for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+			params = append(params, paramSpec(m[1], "path"))
+		}
+		for _, name := range doc.Params {
+			params = append(params, paramSpec(name, "query"))
+		}
+		content := make(map[string]interface{})
+		if doc.Response != nil {
+			content["application/json"] = map[string]interface{}{"schema": schemaOf(reflect.TypeOf(doc.Response), schemas)}
+		}
+		if doc.ContentType != "" {
+			content[doc.ContentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		errorContent := map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(client.ErrorResponse{}), schemas)}}
+		responses := map[string]interface{}{
+			"200":     map[string]interface{}{"description": "OK", "content": content},
+			"default": map[string]interface{}{"description": "error", "content": errorContent},
+		}
+		for status, desc := range doc.Errors {
+			responses[strconv.Itoa(status)] = map[string]interface{}{"description": desc, "content": errorContent}
+		}
+		paths[path] = map[string]interface{}{
+			strings.ToLower(doc.Method): map[string]interface{}{
+				"summary":    doc.Summary,
+				"parameters": params,
+				"responses":  responses,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"openapi":    "3.0.0",
+		"info":       map[string]interface{}{"title": "demo-ssb", "version": Version},
+		"servers":    []interface{}{map[string]interface{}{"url": "http://localhost:8000"}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}, nil
+}
+
+func paramSpec(key, in string) map[string]interface{} {
+	p, ok := apiParams[key]
+	if !ok {
+		panic(fmt.Sprintf("parameter %v is not in apiParams", key))
+	}
+	if p.Name == "" {
+		p.Name = key
+	}
+	schema := map[string]interface{}{"type": p.Type}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	return map[string]interface{}{
+		"name":        p.Name,
+		"in":          in,
+		"required":    in == "path",
+		"description": p.Description,
+		"schema":      schema,
+	}
+}
+
+// schemaOf returns the JSON schema of values of t as encoded by encoding/json.
+// Named structs are added to schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // placeholder, in case t refers to itself
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		props[name] = schemaOf(f.Type, schemas)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+// HandleOpenAPI serves the OpenAPI description of the API.
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPISpec(s.Router)
+	if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(spec); err != nil {
+		fmt.Printf("writing openapi spec to responsewriter: %v", err)
+	}
+}
+
+// openAPICommand implements `main openapi`, printing the OpenAPI description of
+// the API without connecting to Pilosa. It returns 0 on success and 2 on error.
+func openAPICommand() int {
+	s := &Server{queue: NewRunQueue(true)}
+	s.metrics = newHarnessMetrics(s)
+	router, err := s.routes()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	spec, err := openAPISpec(router)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	out, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Println(string(out))
+	return 0
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metricsClient fetches server-side metrics. It has a timeout so a wedged node
+// can't hold up a benchmark run.
+var metricsClient = &http.Client{Timeout: 5 * time.Second}
+
+// MetricsSnapshot is a point-in-time view of a node's expvar values, flattened to
+// dotted keys ("memstats.HeapAlloc"), plus its goroutine count.
+type MetricsSnapshot struct {
+	Vars       map[string]float64
+	Goroutines int
+}
+
+// fetchMetrics snapshots /debug/vars and the goroutine count of the node at baseURL.
+func fetchMetrics(baseURL string) (MetricsSnapshot, error) {
+	snap := MetricsSnapshot{Vars: make(map[string]float64)}
+	resp, err := metricsClient.Get(baseURL + "/debug/vars")
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return snap, fmt.Errorf("getting /debug/vars: %v", resp.Status)
+	}
+	var vars map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return snap, fmt.Errorf("decoding /debug/vars: %v", err)
+	}
+	flattenVars("", vars, snap.Vars)
+
+	// The first line of the goroutine profile is "goroutine profile: total N".
+	resp, err = metricsClient.Get(baseURL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	fmt.Sscanf(line, "goroutine profile: total %d", &snap.Goroutines)
+	return snap, nil
+}
+
+// flattenVars adds every number in v to out, keyed by its dotted path.
+func flattenVars(prefix string, v interface{}, out map[string]float64) {
+	switch v := v.(type) {
+	case float64:
+		out[prefix] = v
+	case map[string]interface{}:
+		for k, vv := range v {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flattenVars(k, vv, out)
+		}
+	}
+}
+
+// metricsDelta computes the change from before to after.
+func metricsDelta(node string, before, after MetricsSnapshot) NodeMetrics {
+	nm := NodeMetrics{
+		Node:       node,
+		Goroutines: after.Goroutines - before.Goroutines,
+		HeapAlloc:  after.Vars["memstats.HeapAlloc"] - before.Vars["memstats.HeapAlloc"],
+		Sys:        after.Vars["memstats.Sys"] - before.Vars["memstats.Sys"],
+		NumGC:      after.Vars["memstats.NumGC"] - before.Vars["memstats.NumGC"],
+		Deltas:     make(map[string]float64),
+	}
+	keys := make([]string, 0, len(after.Vars))
+	for k := range after.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d := after.Vars[k] - before.Vars[k]
+		if d == 0 {
+			continue
+		}
+		nm.Deltas[k] = d
+		if strings.HasPrefix(k, "memstats.") {
+			continue
+		}
+		name := strings.ToLower(k)
+		if strings.Contains(name, "query") {
+			nm.Queries += d
+		}
+		if strings.Contains(name, "hit") {
+			nm.CacheHits += d
+		}
+	}
+	return nm
+}
+
+// clusterStatus returns the state reported by a node's /status endpoint: the
+// cluster state on newer Pilosa versions, or the distinct node states on older ones.
+func clusterStatus(baseURL string) (string, error) {
+	resp, err := metricsClient.Get(baseURL + "/status")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var status struct {
+		State  string `json:"state"`
+		Status struct {
+			Nodes []struct {
+				State string `json:"State"`
+			} `json:"Nodes"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", fmt.Errorf("decoding /status: %v", err)
+	}
+	if status.State != "" {
+		return status.State, nil
+	}
+	var states []string
+	seen := make(map[string]bool)
+	for _, n := range status.Status.Nodes {
+		if !seen[n.State] {
+			seen[n.State] = true
+			states = append(states, n.State)
+		}
+	}
+	return strings.Join(states, ","), nil
+}
+
+// serverSnapshot holds the pre-run metrics of every node of a cluster.
+type serverSnapshot struct {
+	state string
+	nodes []MetricsSnapshot
+	errs  []error
+}
+
+// snapshotServer records the state and metrics of every node of c.
+func snapshotServer(c *Cluster) serverSnapshot {
+	var snap serverSnapshot
+	snap.state, _ = clusterStatus("http://" + c.Addr)
+	for _, node := range c.Nodes {
+		m, err := fetchMetrics("http://" + node.Addr)
+		snap.nodes = append(snap.nodes, m)
+		snap.errs = append(snap.errs, err)
+	}
+	return snap
+}
+
+// serverMetrics snapshots c again and returns the change since before.
+func serverMetrics(c *Cluster, before serverSnapshot) *ServerMetrics {
+	after := snapshotServer(c)
+	sm := &ServerMetrics{StateBefore: before.state, StateAfter: after.state}
+	for n, node := range c.Nodes {
+		if n >= len(before.nodes) {
+			break
+		}
+		if err := before.errs[n]; err != nil {
+			sm.Nodes = append(sm.Nodes, NodeMetrics{Node: node.Addr, Error: err.Error()})
+			continue
+		}
+		if err := after.errs[n]; err != nil {
+			sm.Nodes = append(sm.Nodes, NodeMetrics{Node: node.Addr, Error: err.Error()})
+			continue
+		}
+		sm.Nodes = append(sm.Nodes, metricsDelta(node.Addr, before.nodes[n], after.nodes[n]))
+	}
+	return sm
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeNode serves canned /debug/vars, goroutine profile and /status responses.
+// Every /debug/vars request advances its counters, so consecutive snapshots
+// differ.
+type fakeNode struct {
+	mu       sync.Mutex
+	requests int
+	status   string
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/debug/vars":
+		f.requests++
+		n := f.requests
+		fmt.Fprintf(w, `{
+			"cmdline": ["pilosa", "server"],
+			"memstats": {"HeapAlloc": %d, "Sys": %d, "NumGC": %d, "BySize": [{"Size": 8}]},
+			"index:ssb": {"query:Sum": %d, "query:Count": %d, "rankCache": {"hit": %d, "miss": 7}},
+			"uptime": 100
+		}`, 1000*n, 5000*n, 2*n, 10*n, 3*n, 4*n)
+	case "/debug/pprof/goroutine":
+		fmt.Fprintf(w, "goroutine profile: total %d\n1 @ 0x1\n", 20+5*f.requests)
+	case "/status":
+		fmt.Fprint(w, f.status)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFlattenVars(t *testing.T) {
+	vars := map[string]interface{}{
+		"uptime":  float64(3),
+		"cmdline": []interface{}{"pilosa"},
+		"name":    "ssb",
+		"memstats": map[string]interface{}{
+			"HeapAlloc": float64(10),
+			"BySize":    []interface{}{map[string]interface{}{"Size": float64(8)}},
+		},
+		"index:ssb": map[string]interface{}{
+			"frame:lo_year": map[string]interface{}{"query:Sum": float64(4)},
+		},
+	}
+	out := make(map[string]float64)
+	flattenVars("", vars, out)
+	want := map[string]float64{
+		"uptime":                            3,
+		"memstats.HeapAlloc":                10,
+		"index:ssb.frame:lo_year.query:Sum": 4,
+	}
+	if len(out) != len(want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("%v: got %v, want %v", k, out[k], v)
+		}
+	}
+}
+
+func TestMetricsDelta(t *testing.T) {
+	before := MetricsSnapshot{Goroutines: 10, Vars: map[string]float64{
+		"memstats.HeapAlloc":     100,
+		"memstats.Sys":           1000,
+		"memstats.NumGC":         1,
+		"memstats.Lookups":       5,
+		"index:ssb.query:Sum":    10,
+		"index:ssb.Query:Count":  2,
+		"index:ssb.cache.hit":    3,
+		"index:ssb.cache.miss":   1,
+		"index:ssb.queryhits":    0,
+		"index:ssb.query:TopN":   6,
+		"memstats.PauseTotalNs":  9,
+		"index:ssb.setBit.count": 4,
+	}}
+	after := MetricsSnapshot{Goroutines: 7, Vars: map[string]float64{
+		"memstats.HeapAlloc":     40,
+		"memstats.Sys":           1500,
+		"memstats.NumGC":         4,
+		"memstats.Lookups":       9, // memstats are never counted as queries or hits
+		"index:ssb.query:Sum":    25,
+		"index:ssb.Query:Count":  5, // the match is case-insensitive
+		"index:ssb.cache.hit":    8,
+		"index:ssb.cache.miss":   2,
+		"index:ssb.queryhits":    1, // counts as both a query and a hit
+		"index:ssb.query:TopN":   6, // unchanged, so left out of Deltas
+		"memstats.PauseTotalNs":  9,
+		"index:ssb.setBit.count": 4,
+		"index:ssb.query:Bitmap": 2, // new since before
+	}}
+	nm := metricsDelta("node0:10101", before, after)
+	if nm.Node != "node0:10101" {
+		t.Errorf("Node: got %q", nm.Node)
+	}
+	if nm.Goroutines != -3 || nm.HeapAlloc != -60 || nm.Sys != 500 || nm.NumGC != 3 {
+		t.Errorf("got goroutines %v, heapalloc %v, sys %v, numgc %v, want -3, -60, 500, 3",
+			nm.Goroutines, nm.HeapAlloc, nm.Sys, nm.NumGC)
+	}
+	// 15 (query:Sum) + 3 (Query:Count) + 1 (queryhits) + 2 (query:Bitmap)
+	if nm.Queries != 21 {
+		t.Errorf("Queries: got %v, want 21", nm.Queries)
+	}
+	// 5 (cache.hit) + 1 (queryhits)
+	if nm.CacheHits != 6 {
+		t.Errorf("CacheHits: got %v, want 6", nm.CacheHits)
+	}
+	wantDeltas := map[string]float64{
+		"memstats.HeapAlloc":     -60,
+		"memstats.Sys":           500,
+		"memstats.NumGC":         3,
+		"memstats.Lookups":       4,
+		"index:ssb.query:Sum":    15,
+		"index:ssb.Query:Count":  3,
+		"index:ssb.cache.hit":    5,
+		"index:ssb.cache.miss":   1,
+		"index:ssb.queryhits":    1,
+		"index:ssb.query:Bitmap": 2,
+	}
+	if len(nm.Deltas) != len(wantDeltas) {
+		t.Errorf("Deltas: got %v, want %v", nm.Deltas, wantDeltas)
+	}
+	for k, v := range wantDeltas {
+		if nm.Deltas[k] != v {
+			t.Errorf("Deltas[%v]: got %v, want %v", k, nm.Deltas[k], v)
+		}
+	}
+}
+
+func TestClusterStatus(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+		err              bool
+	}{
+		{name: "cluster state", body: `{"state": "NORMAL", "nodes": [{"id": "a"}]}`, want: "NORMAL"},
+		{
+			name: "node states",
+			body: `{"status": {"Nodes": [{"State": "UP"}, {"State": "DOWN"}, {"State": "UP"}]}}`,
+			want: "UP,DOWN",
+		},
+		{name: "no state", body: `{}`, want: ""},
+		{name: "not json", body: `<html>`, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(&fakeNode{status: test.body})
+			defer srv.Close()
+			got, err := clusterStatus(srv.URL)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchMetrics(t *testing.T) {
+	srv := httptest.NewServer(&fakeNode{})
+	defer srv.Close()
+	snap, err := fetchMetrics(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Goroutines != 25 {
+		t.Errorf("Goroutines: got %v, want 25", snap.Goroutines)
+	}
+	want := map[string]float64{
+		"memstats.HeapAlloc":      1000,
+		"index:ssb.query:Sum":     10,
+		"index:ssb.rankCache.hit": 4,
+		"uptime":                  100,
+	}
+	for k, v := range want {
+		if snap.Vars[k] != v {
+			t.Errorf("Vars[%v]: got %v, want %v", k, snap.Vars[k], v)
+		}
+	}
+
+	srv404 := httptest.NewServer(http.NotFoundHandler())
+	defer srv404.Close()
+	if _, err := fetchMetrics(srv404.URL); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, want a 404 error", err)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	up := httptest.NewServer(&fakeNode{status: `{"state": "NORMAL"}`})
+	defer up.Close()
+	down := httptest.NewServer(&fakeNode{})
+	downAddr := strings.TrimPrefix(down.URL, "http://")
+	down.Close()
+	upAddr := strings.TrimPrefix(up.URL, "http://")
+
+	c := &Cluster{Name: "default", Addr: upAddr, Nodes: []*Node{{Addr: upAddr}, {Addr: downAddr}}}
+	before := snapshotServer(c)
+	if before.state != "NORMAL" {
+		t.Errorf("state: got %q, want NORMAL", before.state)
+	}
+	if len(before.nodes) != 2 || before.errs[0] != nil || before.errs[1] == nil {
+		t.Fatalf("got errors %v, want one for the second node only", before.errs)
+	}
+
+	sm := serverMetrics(c, before)
+	if sm.StateBefore != "NORMAL" || sm.StateAfter != "NORMAL" {
+		t.Errorf("got states %q, %q", sm.StateBefore, sm.StateAfter)
+	}
+	if len(sm.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(sm.Nodes))
+	}
+	nm := sm.Nodes[0]
+	if nm.Node != upAddr || nm.Error != "" {
+		t.Errorf("got node %q with error %q", nm.Node, nm.Error)
+	}
+	// One /debug/vars request each before and after: query:Sum and query:Count
+	// grow by 10 and 3, rankCache.hit by 4.
+	if nm.Queries != 13 || nm.CacheHits != 4 || nm.Goroutines != 5 || nm.HeapAlloc != 1000 {
+		t.Errorf("got queries %v, cachehits %v, goroutines %v, heapalloc %v, want 13, 4, 5, 1000",
+			nm.Queries, nm.CacheHits, nm.Goroutines, nm.HeapAlloc)
+	}
+	if nm := sm.Nodes[1]; nm.Node != downAddr || nm.Error == "" || nm.Queries != 0 {
+		t.Errorf("got %+v for the unreachable node, want an error", nm)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// profileChunk is the length of each CPU profile fetched while a run is in
+// progress. Pilosa can only profile for a fixed number of seconds, so a run is
+// covered by consecutive profiles, which `go tool pprof` merges when given several
+// files.
+const profileChunk = 5 * time.Second
+
+// profiler collects CPU profiles from every node of a cluster until stopped.
+type profiler struct {
+	cluster *Cluster
+	runID   string
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	files []string
+	errs  []string
+}
+
+// startProfiling begins fetching CPU profiles from every node of c, storing them
+// in results/ next to the results file of runID.
+func startProfiling(c *Cluster, runID string) *profiler {
+	p := &profiler{cluster: c, runID: runID, stop: make(chan struct{})}
+	client := &http.Client{Timeout: profileChunk + 30*time.Second}
+	for _, node := range c.Nodes {
+		p.wg.Add(1)
+		go func(node *Node) {
+			defer p.wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-p.stop:
+					return
+				default:
+				}
+				url := fmt.Sprintf("http://%v/debug/pprof/profile?seconds=%d", node.Addr, int(profileChunk.Seconds()))
+				if !p.fetch(client, url, profileName(runID, node, fmt.Sprintf("cpu-%d", n))) {
+					return
+				}
+			}
+		}(node)
+	}
+	return p
+}
+
+// Stop waits for the in-progress CPU profiles to finish, optionally fetches a heap
+// profile from every node, and returns the paths of all profiles written, along
+// with any errors encountered.
+func (p *profiler) Stop(heap bool) ([]string, []string) {
+	close(p.stop)
+	p.wg.Wait()
+	if heap {
+		for _, node := range p.cluster.Nodes {
+			p.fetch(metricsClient, "http://"+node.Addr+"/debug/pprof/heap", profileName(p.runID, node, "heap"))
+		}
+	}
+	return p.files, p.errs
+}
+
+// fetch saves the profile at url to fname, reporting whether it succeeded.
+func (p *profiler) fetch(client *http.Client, url, fname string) bool {
+	err := func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%v", resp.Status)
+		}
+		f, err := os.Create(fname)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(f, resp.Body)
+		return err
+	}()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		fmt.Printf("fetching profile %v: %v\n", url, err)
+		p.errs = append(p.errs, fmt.Sprintf("%v: %v", url, err))
+		return false
+	}
+	p.files = append(p.files, fname)
+	return true
+}
+
+// profileName returns results/<runID>-<node>-<kind>.pprof.
+func profileName(runID string, node *Node, kind string) string {
+	return fmt.Sprintf("results/%v-%v-%v.pprof", runID, strings.Replace(node.Addr, ":", "_", -1), kind)
+}
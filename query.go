@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -43,14 +53,64 @@ func UnravelIndex(index1 int, dim []int) []int {
 	return indexN
 }
 
-type BenchmarkResult struct {
-	Name        string  `json:"name"`
-	Iterations  int     `json:"iterations"`
-	Concurrency int     `json:"concurrency"`
-	BatchSize   int     `json:"batchsize"`
-	Seconds     float64 `json:"seconds"`
-	ColumnCount uint64  `json:"columncount"`
-	Timestamp   int32   `json:"timestamp"`
+// benchmarkRun is a run as the server sees it: the BenchmarkResult it responds
+// with, and what it keeps of the run without sending it.
+type benchmarkRun struct {
+	BenchmarkResult
+
+	rows []ResultRow // the output of every completed query, in completion order
+	err  error       // the error that failed the run, a pilosaError if it came from Pilosa
+}
+
+// row converts a completed QueryResult to a ResultRow.
+func (qr QueryResult) row() ResultRow {
+	return ResultRow{Inputs: qr.intInputs(), Value: qr.outputs[0].(int)}
+}
+
+func (qr QueryResult) intInputs() []int {
+	inputs := make([]int, len(qr.inputs))
+	for n, in := range qr.inputs {
+		inputs[n] = in.(int)
+	}
+	return inputs
+}
+
+// workerStats accumulates what a single worker spent its time on.
+type workerStats struct {
+	retries   int
+	serialize time.Duration
+	request   time.Duration
+	decode    time.Duration
+	collate   time.Duration
+	nodes     map[string][]time.Duration // request latencies by node address
+}
+
+// errBatchTimeout is set on every query of a batch that did not complete within
+// the batch timeout or before the run deadline.
+var errBatchTimeout = errors.New("batch timed out")
+
+// ErrorPolicy controls how a benchmark run reacts to failed batch queries.
+type ErrorPolicy struct {
+	Retries  int           // additional attempts per batch after the first failure
+	Backoff  time.Duration // delay before the first retry, doubled on each attempt
+	Continue bool          // count failures and keep going instead of aborting the run
+}
+
+// errorType classifies a query error for BenchmarkResult.Errors: "pilosa" for
+// errors Pilosa reported, "timeout" for requests that timed out in the network,
+// and "connection" for requests that never got a response.
+func errorType(err error) string {
+	if _, ok := err.(*queryError); ok {
+		return "pilosa"
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return "timeout"
+	}
+	switch err.(type) {
+	case *url.Error, *net.OpError:
+		return "connection"
+	}
+	return "pilosa"
 }
 
 // QuerySet encapsulates a small amount of information necessary for
@@ -109,6 +169,22 @@ func (s *QuerySet) String() string {
 	return fmt.Sprintf("%d queries of form:\n%s", s.iterations, s.Format)
 }
 
+// argLabel matches the frame that each format argument selects a row of.
+var argLabel = regexp.MustCompile(`frame="?(\w+)"?, rowID=%d`)
+
+// Labels names the inputs of the QuerySet after the frames they select rows from,
+// in ArgSets order, e.g. ["p_brand1", "lo_year"] for 2.1.
+func (s *QuerySet) Labels() []string {
+	labels := make([]string, 0, s.dim)
+	for _, m := range argLabel.FindAllStringSubmatch(s.Format, -1) {
+		labels = append(labels, m[1])
+	}
+	for n := len(labels); n < s.dim; n++ {
+		labels = append(labels, fmt.Sprintf("arg%d", n))
+	}
+	return labels
+}
+
 // QueryN generates the Nth query of a QuerySet, as a raw query string
 func (s *QuerySet) QueryN(n int) string {
 	inds := UnravelIndex(n, s.lengths)
@@ -132,68 +208,328 @@ func (s *QuerySet) QueryResultN(n int) QueryResult {
 	return qr
 }
 
+// createResultsFile creates results/<name>-<timestamp><ext>, adding a numeric suffix
+// if a run with the same name started within the same second, in any format.
+func createResultsFile(name string, timestamp int32, ext string) (*os.File, string, error) {
+	if err := os.MkdirAll("results", 0700); err != nil {
+		return nil, "", err
+	}
+	base := fmt.Sprintf("results/%v-%v", name, timestamp)
+	for n := 1; ; n++ {
+		if others, _ := filepath.Glob(base + ".*"); len(others) == 0 {
+			f, err := os.OpenFile(base+ext, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+			if !os.IsExist(err) {
+				return f, base + ext, err
+			}
+		}
+		base = fmt.Sprintf("results/%v-%v-%d", name, timestamp, n)
+	}
+}
+
+// queryOrders lists the supported RunOptions.Order values.
+var queryOrders = []string{"sequential", "random", "reverse"}
+
+// queryOrder returns the order in which the queries of a QuerySet with the given
+// number of iterations are sent. Sequential order walks the cartesian product in
+// UnravelIndex order, so consecutive queries share most of their bitmaps; random
+// and reverse orders reduce how much that flatters Pilosa's caching.
+func queryOrder(opts RunOptions, iterations int) []int {
+	switch opts.Order {
+	case "random":
+		return rand.New(rand.NewSource(opts.Seed)).Perm(iterations)
+	case "reverse":
+		order := arange(0, iterations, 1)
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+		return order
+	}
+	return arange(0, iterations, 1)
+}
+
+// runOptions returns the server's default RunOptions, overridden by any query
+// parameters in r.
+func (s *Server) runOptions(r *http.Request) (RunOptions, error) {
+	opts := RunOptions{
+		Concurrency: s.concurrency,
+		BatchSize:   s.batchSize,
+		Precompute:  s.precompute,
+		Order:       s.order,
+		Cluster:     s.Cluster.Name,
+		Balance:     s.balance,
+		Format:      s.format,
+		Gate:        s.gate,
+		Verify:      s.verifyRuns,
+	}
+	params := r.URL.Query()
+	for param, value := range map[string]*int{"concurrency": &opts.Concurrency, "batchsize": &opts.BatchSize} {
+		if v := params.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return opts, fmt.Errorf("invalid %v: %q", param, v)
+			}
+			*value = n
+		}
+	}
+	for param, value := range map[string]*bool{"precompute": &opts.Precompute, "profile": &opts.Profile, "profileheap": &opts.ProfileHeap, "baseline": &opts.Baseline, "verify": &opts.Verify} {
+		if v := params.Get(param); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %v: %q", param, v)
+			}
+			*value = b
+		}
+	}
+	if v := params.Get("order"); v != "" {
+		opts.Order = v
+	}
+	valid := false
+	for _, order := range queryOrders {
+		valid = valid || opts.Order == order
+	}
+	if !valid {
+		return opts, fmt.Errorf("invalid order: %q, expected one of %v", opts.Order, queryOrders)
+	}
+	if v := params.Get("seed"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid seed: %q", v)
+		}
+		opts.Seed = seed
+	} else if opts.Order == "random" {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if v := params.Get("cluster"); v != "" {
+		if _, ok := s.Clusters[v]; !ok {
+			return opts, fmt.Errorf("unknown cluster: %q, expected one of %v", v, s.ClusterNames())
+		}
+		opts.Cluster = v
+	}
+	if v := params.Get("balance"); v != "" {
+		opts.Balance = v
+	}
+	valid = false
+	for _, balance := range balanceModes {
+		valid = valid || opts.Balance == balance
+	}
+	if !valid {
+		return opts, fmt.Errorf("invalid balance: %q, expected one of %v", opts.Balance, balanceModes)
+	}
+	if v := params.Get("format"); v != "" {
+		opts.Format = v
+	}
+	if _, ok := resultFormats[opts.Format]; !ok {
+		return opts, fmt.Errorf("invalid format: %q, expected one of txt, csv, jsonl or arrow", opts.Format)
+	}
+	gate, err := parseRegressionPolicy(opts.Gate, params.Get("metric"), params.Get("tolerance"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Gate = gate
+	return opts, nil
+}
+
+// queryBatch is a batch of queries sent in a single request. raw is the batch body,
+// which is built by the worker unless it was precomputed.
+type queryBatch struct {
+	queries []QueryResult
+	raw     string
+}
+
+// batchRaw concatenates the raw queries of a batch into a single request body.
+func batchRaw(queries []QueryResult) string {
+	var buf strings.Builder
+	for _, q := range queries {
+		buf.WriteString(q.raw)
+	}
+	return buf.String()
+}
+
+// precomputeBatches materializes every query and batch body of qs, in the given
+// order, returning the time spent generating queries and building batch bodies.
+func precomputeBatches(qs QuerySet, order []int, batchSize int) ([]queryBatch, time.Duration, time.Duration) {
+	start := time.Now()
+	var batches []queryBatch
+	for n := 0; n < qs.iterations; n += batchSize {
+		queries := make([]QueryResult, 0, batchSize)
+		for k := n; k < n+batchSize && k < qs.iterations; k++ {
+			queries = append(queries, qs.QueryResultN(order[k]))
+		}
+		batches = append(batches, queryBatch{queries: queries})
+	}
+	generate := time.Since(start)
+
+	start = time.Now()
+	for n := range batches {
+		batches[n].raw = batchRaw(batches[n].queries)
+	}
+	return batches, generate, time.Since(start)
+}
+
 // RunSumMultiBatch sends queries in a QuerySet to the cluster in a configurable combination of
 // batchSize and concurrency. Examples:
 // concurrency=1, batchSize=(iteration count) -> equivalent to RunSumBatch
 // concurrency=N, batchSize=1                 -> equivalent to RunSumConcurrent(N)
 // concurrency=N, batchSize=10                -> sends concurrent batches of 10 queries
-func (s *Server) RunSumMultiBatch(qs QuerySet, concurrency, batchSize int) BenchmarkResult {
-	batches := make(chan []QueryResult)
+// Failed batches are retried and then handled according to s.errorPolicy. Batches slower
+// than s.batchTimeout, and any still running when s.runTimeout expires, are reported as timed out.
+// With opts.Precompute, queries are generated before the timer starts.
+// Every run is recorded in the server's store.
+// run is the queued request the run belongs to, or nil.
+func (s *Server) RunSumMultiBatch(qs QuerySet, opts RunOptions, run *QueuedRun) benchmarkRun {
+	bench := s.runSumMultiBatch(qs, opts, run)
+	c, ok := s.Clusters[opts.Cluster]
+	if !ok {
+		c = s.Cluster
+	}
+	bench.Meta = s.runMetadata(c, qs, opts)
+	if bench.err != nil {
+		bench.Error = bench.err.Error()
+		bench.Code = runError(bench).Code
+	}
+	bench.Partial = bench.Seconds >= 0 && (bench.Failed > 0 || bench.TimedOut > 0 || bench.DeadlineExceeded)
+	bench.Overlapped = s.queue.Overlapped(run)
+	if opts.Verify && bench.RunID != "" {
+		bench.Verification = s.verify(bench)
+	}
+	bench.Regression = s.checkRegression(bench.BenchmarkResult, opts.Gate)
+	s.recordRun(bench)
+	if opts.Baseline {
+		s.markBaseline(bench.BenchmarkResult)
+	}
+	return bench
+}
+
+func (s *Server) runSumMultiBatch(qs QuerySet, opts RunOptions, run *QueuedRun) benchmarkRun {
+	concurrency, batchSize := opts.Concurrency, opts.BatchSize
+	c, ok := s.Clusters[opts.Cluster]
+	if !ok {
+		c = s.Cluster
+	}
+	batches := make(chan queryBatch)
 	results := make(chan QueryResult)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
 
 	// Create results file.
 	timestamp := int32(time.Now().Unix())
-	fname := fmt.Sprintf("results/%v-%v.txt", qs.Name, timestamp)
-	err := os.MkdirAll("results", 0700)
-	if err != nil {
-		fmt.Printf("creating results directory: %v\n", err)
-		return BenchmarkResult{qs.Name, 0, 0, 0, -1, 0, timestamp}
+	failed := benchmarkRun{BenchmarkResult: BenchmarkResult{Name: qs.Name, Cluster: c.Name, Seconds: -1, Timestamp: timestamp}}
+	ext, ok := resultFormats[opts.Format]
+	if !ok {
+		opts.Format, ext = "txt", ".txt"
 	}
-	f, err := os.Create(fname)
+	f, fname, err := createResultsFile(qs.Name, timestamp, ext)
 	if err != nil {
 		fmt.Printf("creating results file: %v\n", err)
-		return BenchmarkResult{qs.Name, 0, 0, 0, -1, 0, timestamp}
+		failed.err = err
+		return failed
+	}
+	defer f.Close()
+	runID := strings.TrimSuffix(filepath.Base(fname), ext)
+	failed.RunID = runID
+	out := &countingWriter{w: f}
+	rw, err := newResultWriter(opts.Format, out, qs.Labels())
+	if err != nil {
+		fmt.Printf("creating result writer: %v\n", err)
+		failed.err = err
+		return failed
+	}
+
+	bench := benchmarkRun{BenchmarkResult: BenchmarkResult{
+		Name:        qs.Name,
+		Iterations:  qs.iterations,
+		Concurrency: concurrency,
+		BatchSize:   batchSize,
+		ColumnCount: c.NumLineOrders,
+		RunID:       runID,
+		Cluster:     c.Name,
+		Balance:     opts.Balance,
+		Timestamp:   timestamp,
+		Precomputed: opts.Precompute,
+		Order:       opts.Order,
+		Seed:        opts.Seed,
+	}}
+	order := queryOrder(opts, qs.iterations)
+	var prepared []queryBatch
+	if opts.Precompute {
+		var generate, serialize time.Duration
+		prepared, generate, serialize = precomputeBatches(qs, order, batchSize)
+		bench.Phases.Generate = generate.Seconds()
+		bench.Phases.Serialize = serialize.Seconds()
+	}
+
+	// Snapshot Pilosa's own metrics outside the timed section.
+	var serverBefore serverSnapshot
+	if s.serverMetrics {
+		serverBefore = snapshotServer(c)
 	}
 
 	// Add queries to channel
+	generateTime := make(chan time.Duration, 1)
 	go func() {
-		// qRawBatch := ""
+		defer close(batches)
+		var generate time.Duration
+		defer func() { generateTime <- generate }()
+		send := func(b queryBatch) bool {
+			select {
+			case batches <- b:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		if opts.Precompute {
+			for _, b := range prepared {
+				if !send(b) {
+					return
+				}
+			}
+			return
+		}
 		qBatch := make([]QueryResult, 0, batchSize)
-		batchCount := 0
 		for n := 0; n < qs.iterations; n++ {
-			qq := qs.QueryResultN(n)
-			qBatch = append(qBatch, qq)
-
-			batchCount++
-			if batchCount == batchSize {
-				batches <- qBatch
-				batchCount = 0
+			t := time.Now()
+			qBatch = append(qBatch, qs.QueryResultN(order[n]))
+			generate += time.Since(t)
+			if len(qBatch) == batchSize || n == qs.iterations-1 {
+				if !send(queryBatch{queries: qBatch}) {
+					return
+				}
 				qBatch = make([]QueryResult, 0, batchSize)
 			}
 		}
-		if batchCount > 0 {
-			batches <- qBatch
-		}
-		close(batches)
 	}()
 
+	var prof *profiler
+	if opts.Profile {
+		prof = startProfiling(c, runID)
+	}
+
 	start := time.Now()
 	// Run setup query.
 	if qs.setup != "" {
-		_, err := s.Client.Query(s.Index.RawQuery(qs.setup), nil)
+		_, _, err := s.queryWithRetry(c.Nodes[0], qs.setup, done)
 		if err != nil {
 			fmt.Printf("error in setup: %v\n", err)
-			return BenchmarkResult{qs.Name, 0, 0, 0, -1, 0, timestamp}
+			failed.err = pilosaError{err}
+			stop()
+			if prof != nil {
+				prof.Stop(false)
+			}
+			return failed
 		}
 	}
 
 	// Start workers.
 	var wg = &sync.WaitGroup{}
+	var stats = make([]workerStats, concurrency)
 	for n := 0; n < concurrency; n++ {
 		wg.Add(1)
-		go func() {
-			s.runRawSumBatchQuery(batches, results, wg)
-		}()
+		go func(n int) {
+			defer wg.Done()
+			stats[n] = s.runRawSumBatchQuery(c, qs.Name, opts.Balance, batches, results, done)
+		}(n)
 	}
 	go func() {
 		wg.Wait()
@@ -201,87 +537,323 @@ func (s *Server) RunSumMultiBatch(qs QuerySet, concurrency, batchSize int) Bench
 	}()
 	// TODO sort
 
-	// Write results to file.
-	defer f.Close()
-	nn := 0
-	for res := range results {
-		if res.err != nil {
-			fmt.Printf("running query: %v\n", res.err)
-			return BenchmarkResult{qs.Name, 0, 0, 0, -1, 0, timestamp}
-		}
-		n, err := f.WriteString(fmt.Sprintf("%v %v\n", res.outputs[0], res.inputs))
-		nn += n
+	var deadline <-chan time.Time
+	if s.runTimeout > 0 {
+		timer := time.NewTimer(s.runTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// Write results to file. On abort, keep draining so workers can exit, and keep
+	// counting the failures of batches that were already in flight.
+	var writeTime time.Duration
+	write := func(w func() error) {
+		t := time.Now()
+		err := w()
+		writeTime += time.Since(t)
 		if err != nil {
 			fmt.Printf("writing results file: %v\n", err)
-			break
+			if bench.err == nil {
+				bench.err = err
+			}
+			bench.Aborted = true
+			stop()
 		}
 	}
+	received := 0
+	s.queue.Progress(run, qs.Name, qs.iterations)
+	for results != nil {
+		var res QueryResult
+		var ok bool
+		select {
+		case res, ok = <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			received++
+			run.SetDone(received)
+		case <-deadline:
+			fmt.Printf("run deadline exceeded for %v\n", qs.Name)
+			bench.DeadlineExceeded = true
+			deadline = nil
+			stop()
+			continue
+		}
+		if res.err == errBatchTimeout {
+			if bench.Aborted {
+				continue // cancelled by the abort
+			}
+			bench.TimedOut++
+			bench.TimedOutInputs = append(bench.TimedOutInputs, res.inputs)
+			inputs := res.intInputs()
+			write(func() error { return rw.WriteTimeout(inputs) })
+			continue
+		}
+		if res.err != nil {
+			if bench.Errors == nil {
+				bench.Errors = make(map[string]int)
+			}
+			bench.Failed++
+			bench.Errors[errorType(res.err)]++
+			bench.FailedInputs = append(bench.FailedInputs, res.inputs)
+			if !s.errorPolicy.Continue && !bench.Aborted {
+				fmt.Printf("aborting %v: %v\n", qs.Name, res.err)
+				bench.err = pilosaError{res.err}
+				bench.Aborted = true
+				stop()
+			}
+			continue
+		}
+		if bench.Aborted {
+			continue
+		}
+		bench.Completed++
+		row := res.row()
+		bench.rows = append(bench.rows, row)
+		write(func() error { return rw.WriteRow(row) })
+	}
+	t := time.Now()
+	if err := rw.Close(); err != nil {
+		fmt.Printf("writing results file: %v\n", err)
+	}
+	writeTime += time.Since(t)
+	bench.Phases.Generate += (<-generateTime).Seconds()
+	bench.Phases.Write = writeTime.Seconds()
+	nodeLatencies := make(map[string][]time.Duration)
+	for _, ws := range stats {
+		bench.Retries += ws.retries
+		bench.Phases.Serialize += ws.serialize.Seconds()
+		bench.Phases.Request += ws.request.Seconds()
+		bench.Phases.Decode += ws.decode.Seconds()
+		bench.Phases.Collate += ws.collate.Seconds()
+		for addr, latencies := range ws.nodes {
+			nodeLatencies[addr] = append(nodeLatencies[addr], latencies...)
+		}
+	}
+	var allLatencies []time.Duration
+	for _, node := range c.Nodes {
+		if latencies, ok := nodeLatencies[node.Addr]; ok {
+			bench.Nodes = append(bench.Nodes, newLatencyStats(node.Addr, latencies))
+			allLatencies = append(allLatencies, latencies...)
+		}
+	}
+	bench.Latency = newLatencyStats("all", allLatencies)
+	if bench.Aborted {
+		bench.Seconds = -1
+		if prof != nil {
+			bench.Profiles, bench.ProfileErrors = prof.Stop(opts.ProfileHeap)
+		}
+		if s.serverMetrics {
+			bench.Server = serverMetrics(c, serverBefore)
+		}
+		return bench
+	}
 
 	// Run teardown query.
 	if qs.teardown != "" {
-		_, err := s.Client.Query(s.Index.RawQuery(qs.teardown), nil)
+		_, _, err := s.queryWithRetry(c.Nodes[0], qs.teardown, nil)
 		if err != nil {
 			fmt.Printf("error in teardown: %v\n", err)
-			return BenchmarkResult{qs.Name, 0, 0, 0, -1, 0, timestamp}
+			failed.err = pilosaError{err}
+			if prof != nil {
+				prof.Stop(false)
+			}
+			return failed
 		}
 	}
 
-	seconds := time.Now().Sub(start).Seconds()
-	fmt.Printf("wrote %d bytes to %v\n", nn, fname)
+	// Stopping the profiler waits out the current CPU profile, so the run is timed first.
+	bench.Seconds = time.Now().Sub(start).Seconds()
+	if prof != nil {
+		bench.Profiles, bench.ProfileErrors = prof.Stop(opts.ProfileHeap)
+	}
+	fmt.Printf("wrote %d bytes to %v\n", out.n, fname)
+	if s.serverMetrics {
+		bench.Server = serverMetrics(c, serverBefore)
+	}
 
 	// Return result object.
-	return BenchmarkResult{
-		qs.Name,
-		qs.iterations,
-		concurrency,
-		batchSize,
-		seconds,
-		s.NumLineOrders,
-		timestamp,
-	}
+	return bench
 }
 
 // runRawSumBatchQuery sends RawQueries to the cluster, then sends the Sum from each result to a result channel.
-func (s *Server) runRawSumBatchQuery(batches <-chan []QueryResult, results chan<- QueryResult, wg *sync.WaitGroup) {
+// Batches are spread across the cluster's nodes according to balance.
+// It returns its retry count and the time spent in each phase.
+func (s *Server) runRawSumBatchQuery(c *Cluster, name, balance string, batches <-chan queryBatch, results chan<- QueryResult, done <-chan struct{}) workerStats {
 	// Receives batches of queries as []QueryResult. Each slice is compiled into a
 	// a raw batch query, a single request is sent, and the results are collated
 	// with the input []QueryResult, then sent back on the results channel one at a time.
-	defer wg.Done()
-	for batch := range batches {
-		raw := ""
-		for _, q := range batch {
-			raw += q.raw
+	// A batch that still fails after retrying is sent back with err set on every query.
+	var ws workerStats
+	for b := range batches {
+		select {
+		case <-done:
+			return ws
+		default:
+		}
+		batch, raw := b.queries, b.raw
+		if raw == "" {
+			t := time.Now()
+			raw = batchRaw(batch)
+			ws.serialize += time.Since(t)
 		}
-		response, err := s.Client.Query(s.Index.RawQuery(raw), nil)
+
+		node := c.pickNode(balance)
+		t := time.Now()
+		response, qstats, err := s.queryWithRetry(node, raw, done)
+		latency := time.Since(t)
+		ws.request += qstats.request
+		ws.decode += qstats.decode
+		if ws.nodes == nil {
+			ws.nodes = make(map[string][]time.Duration)
+		}
+		ws.nodes[node.Addr] = append(ws.nodes[node.Addr], latency)
+		ws.retries += qstats.retries
+		if err == nil && len(response.Results) != len(batch) {
+			err = &queryError{Status: http.StatusOK, Message: fmt.Sprintf("got %d results for %d queries", len(response.Results), len(batch))}
+		}
+		s.metrics.observeBatch(name, node.Addr, len(batch), qstats.retries, latency, err)
 
 		if err != nil {
-			fmt.Printf("in runRawSumBatchQuery: %vfailed with: %v\n", raw, err)
-			results <- QueryResult{raw, []interface{}{}, []interface{}{}, err}
+			if err != errBatchTimeout {
+				fmt.Printf("in runRawSumBatchQuery: %vfailed with: %v\n", raw, err)
+			}
+			for n := range batch {
+				batch[n].err = err
+				results <- batch[n]
+			}
+			continue
+		}
+		t = time.Now()
+		for n, res := range response.Results {
+			batch[n].outputs = []interface{}{res.sum()}
 		}
-		for n, res := range response.Results() {
-			batch[n].outputs = []interface{}{int(res.Sum)}
+		ws.collate += time.Since(t)
+		for n := range batch {
 			results <- batch[n]
 		}
 	}
+	return ws
+}
+
+// queryWithRetry sends a raw query, retrying with exponential backoff according to
+// s.errorPolicy. It returns the response, the number of retries and the time spent
+// on every attempt, and the last error. Every attempt is a new request, so a retry
+// isn't affected by the failure before it. Timed-out batches are not retried.
+func (s *Server) queryWithRetry(node *Node, raw string, done <-chan struct{}) (*queryResponse, queryStats, error) {
+	var stats queryStats
+	backoff := s.errorPolicy.Backoff
+	for ; ; stats.retries++ {
+		response, attempt, err := s.queryWithTimeout(node, raw, done)
+		stats.request += attempt.request
+		stats.decode += attempt.decode
+		if err == nil || err == errBatchTimeout || stats.retries >= s.errorPolicy.Retries {
+			return response, stats, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			return nil, stats, err
+		}
+		backoff *= 2
+	}
+}
+
+// queryWithTimeout sends a raw query to node, cancelling the request after
+// s.batchTimeout or when done is closed, whichever comes first.
+func (s *Server) queryWithTimeout(node *Node, raw string, done <-chan struct{}) (*queryResponse, queryStats, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.batchTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), s.batchTimeout)
+	}
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	atomic.AddInt64(&node.inflight, 1)
+	s.metrics.inFlight.Inc()
+	response, stats, err := node.query(ctx, raw)
+	atomic.AddInt64(&node.inflight, -1)
+	s.metrics.inFlight.Dec()
+	if err != nil && ctx.Err() != nil {
+		return nil, stats, errBatchTimeout
+	}
+	return response, stats, err
 }
 
+// HandleQuery runs a query once, or as a grid of runs, e.g. /query/2.1?concurrency=8.
+// A failed run responds with a JSON error. A grid only does if every run failed;
+// otherwise its failed runs have their error and code.
 func (s *Server) HandleQuery(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("handling %v\n", r.URL.Path)
 	vars := mux.Vars(r)
 	qname, qtype := vars["qname"], vars["qtype"]
 
+	if qtype != "query" && qtype != "grid" {
+		writeError(w, APIError{
+			Status:  http.StatusNotFound,
+			Code:    codeUnknownQueryType,
+			Message: fmt.Sprintf("unknown query type: %q, expected query or grid", qtype),
+		})
+		return
+	}
 	qs := getQuerySet(qname)
-	var results []BenchmarkResult
+	if qs.Name == "" {
+		writeError(w, APIError{
+			Status:  http.StatusNotFound,
+			Code:    codeUnknownQuery,
+			Message: fmt.Sprintf("unknown query: %q", qname),
+		})
+		return
+	}
+	opts, err := s.runOptions(r)
+	// With wait=false, fail with 409 Conflict instead of queueing behind other runs.
+	wait := true
+	if v := r.URL.Query().Get("wait"); err == nil && v != "" {
+		if wait, err = strconv.ParseBool(v); err != nil {
+			err = fmt.Errorf("invalid wait: %q", v)
+		}
+	}
+	if err != nil {
+		writeError(w, APIError{
+			Status:  http.StatusBadRequest,
+			Code:    codeInvalidParameter,
+			Message: err.Error(),
+		})
+		return
+	}
+	run, ok := s.queue.TryAcquire(r.URL.Path)
+	if !ok && !wait {
+		writeError(w, APIError{
+			Status:  http.StatusConflict,
+			Code:    codeBusy,
+			Message: "another benchmark run is in progress or queued",
+		})
+		return
+	} else if !ok {
+		if run, err = s.queue.Acquire(r.Context(), r.URL.Path); err != nil {
+			fmt.Printf("%v left the queue: %v\n", r.URL, err)
+			return
+		}
+	}
+	var results []benchmarkRun
 	if qtype == "query" {
-		results = []BenchmarkResult{
-			s.RunSumMultiBatch(qs, s.concurrency, s.batchSize),
+		results = []benchmarkRun{
+			s.RunSumMultiBatch(qs, opts, run),
 		}
 	} else if qtype == "grid" {
 		concurrency := []int{8, 16, 32}
 		batchSize := []int{2, 4, 8}
 		for _, c := range concurrency {
 			for _, b := range batchSize {
-				results = append(results, s.RunSumMultiBatch(qs, c, b))
+				opts.Concurrency, opts.BatchSize = c, b
+				results = append(results, s.RunSumMultiBatch(qs, opts, run))
 			}
 		}
 		//	} else if qtype == "register" {
@@ -289,14 +861,36 @@ func (s *Server) HandleQuery(w http.ResponseWriter, r *http.Request) {
 		//			s.RunSumMultiBatchRegister(qs, s.concurrency, s.batchSize),
 		//		}
 	}
+	s.queue.Release(run)
 
+	// A grid responds with every run, failed or not, unless all of them failed.
+	var benches []BenchmarkResult
+	failed := 0
+	for _, res := range results {
+		if res.Seconds == -1 {
+			failed++
+		}
+		benches = append(benches, res.BenchmarkResult)
+	}
+	if failed == len(results) {
+		writeError(w, runError(results[0]))
+		return
+	}
 	enc := json.NewEncoder(w)
-	err := enc.Encode(results)
+	err = enc.Encode(benches)
 	if err != nil {
-		fmt.Printf("writing results: %v to responsewriter: %v", results, err)
+		fmt.Printf("writing results: %v to responsewriter: %v", benches, err)
 	}
 }
 
+// ssbQueries lists the standard SSB queries, in flight order.
+var ssbQueries = []string{
+	"1.1", "1.2", "1.3",
+	"2.1", "2.2", "2.3",
+	"3.1", "3.2", "3.3", "3.4",
+	"4.1", "4.2", "4.3",
+}
+
 func getQuerySet(qname string) QuerySet {
 	var qs QuerySet
 	switch qname {
@@ -658,6 +1252,7 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 	case "4.1":
 		years := arange(1992, 1999, 1)
 		nations := arange(0, 5, 1)
+		// manufacturers MFGR#1 and MFGR#2 are p_mfgr rows 0 and 1; see ssbref.EncodePart
 		qs = NewQuerySet(
 			qname,
 			`Sum(
@@ -666,8 +1261,8 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 		Bitmap(frame="lo_year", rowID=%d),
 		Bitmap(frame="s_region", rowID=0),
 		Union(
+			Bitmap(frame="p_mfgr", rowID=0),
 			Bitmap(frame="p_mfgr", rowID=1),
-			Bitmap(frame="p_mfgr", rowID=2),
 		)
 	),
 	frame="lo_profit", field="lo_profit")`,
@@ -677,6 +1272,7 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 	case "4.1r":
 		years := arange(1992, 1999, 1)
 		nations := arange(0, 5, 1)
+		// manufacturers MFGR#1 and MFGR#2 are p_mfgr rows 0 and 1; see ssbref.EncodePart
 		qs = NewQuerySet(
 			qname,
 			`Sum(
@@ -686,8 +1282,8 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 			Bitmap(frame="lo_year", rowID=%d),
 			Bitmap(frame="s_region", rowID=0),
 			Union(
+				Bitmap(frame="p_mfgr", rowID=0),
 				Bitmap(frame="p_mfgr", rowID=1),
-				Bitmap(frame="p_mfgr", rowID=2),
 			)
 		)
 	),
@@ -698,6 +1294,7 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 	case "4.1rb":
 		years := arange(1992, 1999, 1)
 		nations := arange(0, 5, 1)
+		// manufacturers MFGR#1 and MFGR#2 are p_mfgr rows 0 and 1; see ssbref.EncodePart
 		qs = NewRegisterQuerySet(
 			qname,
 			`Sum(
@@ -710,8 +1307,8 @@ frame="lo_revenue_computed", field="lo_revenue_computed")`,
 	Intersect(
 		Bitmap(frame="s_region", rowID=0),
 		Union(
+			Bitmap(frame="p_mfgr", rowID=0),
 			Bitmap(frame="p_mfgr", rowID=1),
-			Bitmap(frame="p_mfgr", rowID=2),
 		)), id=41)`,
 			`Purge(id=41)`,
 			[][]int{nations, years},
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pilosa/demo-ssb/ssbref"
+	pilosa "github.com/pilosa/go-pilosa"
+)
+
+// pilosaStub answers /index/{index}/query like Pilosa does for Sum queries, with
+// one SumCount per top-level call of PQL whose Sum is the call's length, after delay. It
+// fails the first fail requests, and every request containing failOn, with a
+// Pilosa error.
+type pilosaStub struct {
+	fail   int
+	failOn string
+	delay  time.Duration
+
+	mu       sync.Mutex
+	requests []time.Time
+}
+
+func (p *pilosaStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	p.mu.Lock()
+	n := len(p.requests)
+	p.requests = append(p.requests, time.Now())
+	p.mu.Unlock()
+	select {
+	case <-time.After(p.delay):
+	case <-r.Context().Done():
+		return
+	}
+
+	response := new(queryResponse)
+	if n < p.fail || (p.failOn != "" && bytes.Contains(body, []byte(p.failOn))) {
+		w.WriteHeader(http.StatusInternalServerError)
+		response.Err = "stub failure"
+	} else {
+		for _, call := range splitCalls(string(body)) {
+			response.Results = append(response.Results, &queryResult{SumCount: &sumCount{Sum: int64(len(call)), Count: 1}})
+		}
+	}
+	buf, _ := proto.Marshal(response)
+	w.Write(buf)
+}
+
+// splitCalls splits a PQL request body into its top-level calls.
+func splitCalls(pql string) []string {
+	var calls []string
+	start, depth := 0, 0
+	for i, c := range pql {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				calls = append(calls, strings.TrimSpace(pql[start:i+1]))
+				start = i + 1
+			}
+		}
+	}
+	return calls
+}
+
+// newTestServer returns a Server whose default cluster is the single node at addr,
+// writing its results files under a temporary directory.
+func newTestServer(t *testing.T, addr string) *Server {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	s := &Server{Clusters: make(map[string]*Cluster), queue: NewRunQueue(true)}
+	s.Cluster = addTestCluster(t, s, "default", addr)
+	s.metrics = newHarnessMetrics(s)
+	return s
+}
+
+// addTestCluster adds a cluster of the single node at addr to s.
+func addTestCluster(t *testing.T, s *Server, name, addr string) *Cluster {
+	index, err := pilosa.NewIndex("ssb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Cluster{Name: name, Addr: addr, Index: index, Nodes: []*Node{newNode(addr, index.Name())}}
+	s.Clusters[name] = c
+	return c
+}
+
+func TestQueryResponseDecoding(t *testing.T) {
+	// Results[0] = {SumCount: {Sum: 10, Count: 3}}, Results[1] = {N: 7}
+	buf := []byte{0x12, 0x06, 0x2a, 0x04, 0x08, 0x0a, 0x10, 0x03, 0x12, 0x02, 0x10, 0x07}
+	response := new(queryResponse)
+	if err := proto.Unmarshal(buf, response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(response.Results))
+	}
+	if sum := response.Results[0].sum(); sum != 10 {
+		t.Errorf("got sum %d, want 10", sum)
+	}
+	if count := response.Results[0].SumCount.Count; count != 3 {
+		t.Errorf("got count %d, want 3", count)
+	}
+	if n := response.Results[1].N; n != 7 {
+		t.Errorf("got N %d, want 7", n)
+	}
+}
+
+// timeoutError is a network error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorType(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, _, refused := newNode(closed.Listener.Addr().String(), "ssb").query(context.Background(), "Count()")
+
+	stub := httptest.NewServer(&pilosaStub{fail: 1})
+	defer stub.Close()
+	_, _, failed := newNode(stub.Listener.Addr().String(), "ssb").query(context.Background(), "Count()")
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	_, _, missing := newNode(notFound.Listener.Addr().String(), "ssb").query(context.Background(), "Count()")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"connection refused", refused, "connection"},
+		{"pilosa error", failed, "pilosa"},
+		{"http status", missing, "pilosa"},
+		{"network timeout", &url.Error{Op: "Post", URL: "http://pilosa", Err: &net.OpError{Op: "read", Err: timeoutError{}}}, "timeout"},
+		{"result count", &queryError{Status: http.StatusOK, Message: "got 1 results for 2 queries"}, "pilosa"},
+	}
+	for _, test := range tests {
+		if test.err == nil {
+			t.Errorf("%v: no error", test.name)
+			continue
+		}
+		if got := errorType(test.err); got != test.want {
+			t.Errorf("%v: errorType(%v) = %q, want %q", test.name, test.err, got, test.want)
+		}
+	}
+	if qerr, ok := failed.(*queryError); !ok || qerr.Message != "stub failure" || qerr.Status != http.StatusInternalServerError {
+		t.Errorf("pilosa error: got %#v, want stub failure with status 500", failed)
+	}
+}
+
+func TestQueryWithRetry(t *testing.T) {
+	const backoff = 10 * time.Millisecond
+	tests := []struct {
+		name        string
+		retries     int
+		fail        int
+		wantRetries int
+		wantErr     bool
+	}{
+		{"success", 2, 0, 0, false},
+		{"recovers", 3, 2, 2, false},
+		{"gives up", 2, 5, 2, true},
+		{"no retries", 0, 1, 0, true},
+	}
+	for _, test := range tests {
+		stub := &pilosaStub{fail: test.fail}
+		srv := httptest.NewServer(stub)
+		s := newTestServer(t, srv.Listener.Addr().String())
+		s.errorPolicy = ErrorPolicy{Retries: test.retries, Backoff: backoff}
+
+		response, stats, err := s.queryWithRetry(s.Nodes[0], "Count()\n", nil)
+		srv.Close()
+		if stats.retries != test.wantRetries {
+			t.Errorf("%v: got %d retries, want %d", test.name, stats.retries, test.wantRetries)
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: got error %v, want error: %v", test.name, err, test.wantErr)
+		}
+		if err == nil && len(response.Results) != 1 {
+			t.Errorf("%v: got %d results, want 1", test.name, len(response.Results))
+		}
+		if len(stub.requests) != test.wantRetries+1 {
+			t.Errorf("%v: got %d requests, want %d", test.name, len(stub.requests), test.wantRetries+1)
+		}
+		// The backoff doubles before each retry.
+		for n := 1; n < len(stub.requests); n++ {
+			want := backoff << uint(n-1)
+			if gap := stub.requests[n].Sub(stub.requests[n-1]); gap < want {
+				t.Errorf("%v: retry %d after %v, want at least %v", test.name, n, gap, want)
+			}
+		}
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	qs := NewQuerySet("test", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_year", rowID=%d))`, [][]int{{1, 2, 3, 4}})
+	opts := RunOptions{Concurrency: 1, BatchSize: 1, Order: "sequential", Balance: "round-robin", Format: "txt"}
+	tests := []struct {
+		name          string
+		policy        ErrorPolicy
+		fail          int
+		wantCompleted int
+		wantFailed    int
+		wantRetries   int
+		wantAborted   bool
+		wantInputs    [][]interface{}
+	}{
+		{"abort", ErrorPolicy{}, 0, 1, 1, 0, true, [][]interface{}{{2}}},
+		{"continue", ErrorPolicy{Continue: true}, 0, 3, 1, 0, false, [][]interface{}{{2}}},
+		{"retry then abort", ErrorPolicy{Retries: 2}, 0, 1, 1, 2, true, [][]interface{}{{2}}},
+		{"retry recovers", ErrorPolicy{Retries: 1, Continue: true}, 1, 3, 1, 2, false, [][]interface{}{{2}}},
+	}
+	for _, test := range tests {
+		stub := &pilosaStub{failOn: "rowID=2)", fail: test.fail}
+		srv := httptest.NewServer(stub)
+		s := newTestServer(t, srv.Listener.Addr().String())
+		s.errorPolicy = test.policy
+
+		bench := s.runSumMultiBatch(qs, opts, nil)
+		srv.Close()
+		if bench.Completed != test.wantCompleted || bench.Failed != test.wantFailed || bench.Retries != test.wantRetries {
+			t.Errorf("%v: got %d completed, %d failed, %d retries, want %d, %d, %d", test.name,
+				bench.Completed, bench.Failed, bench.Retries, test.wantCompleted, test.wantFailed, test.wantRetries)
+		}
+		if bench.Aborted != test.wantAborted || (bench.Seconds == -1) != test.wantAborted || (bench.err != nil) != test.wantAborted {
+			t.Errorf("%v: got aborted %v, seconds %v, error %v, want aborted %v", test.name, bench.Aborted, bench.Seconds, bench.err, test.wantAborted)
+		}
+		if bench.Failed > 0 && bench.Errors["pilosa"] != bench.Failed {
+			t.Errorf("%v: got errors %v, want %d pilosa", test.name, bench.Errors, bench.Failed)
+		}
+		if !reflect.DeepEqual(bench.FailedInputs, test.wantInputs) {
+			t.Errorf("%v: got failed inputs %v, want %v", test.name, bench.FailedInputs, test.wantInputs)
+		}
+	}
+}
+
+func TestQueryWithTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		stub         *pilosaStub
+		batchTimeout time.Duration
+		doneAfter    time.Duration // close done after this long, if set
+		wantErr      error         // errBatchTimeout, or nil for any other error
+		wantFail     bool
+	}{
+		{"fast", &pilosaStub{}, 0, 0, nil, false},
+		{"within timeout", &pilosaStub{delay: 10 * time.Millisecond}, time.Second, 0, nil, false},
+		{"batch timeout", &pilosaStub{delay: time.Second}, 20 * time.Millisecond, 0, errBatchTimeout, true},
+		{"done", &pilosaStub{delay: time.Second}, 0, 20 * time.Millisecond, errBatchTimeout, true},
+		{"pilosa error", &pilosaStub{fail: 1}, time.Second, 0, nil, true},
+	}
+	for _, test := range tests {
+		srv := httptest.NewServer(test.stub)
+		s := newTestServer(t, srv.Listener.Addr().String())
+		s.batchTimeout = test.batchTimeout
+		done := make(chan struct{})
+		if test.doneAfter > 0 {
+			time.AfterFunc(test.doneAfter, func() { close(done) })
+		}
+
+		start := time.Now()
+		response, _, err := s.queryWithTimeout(s.Nodes[0], "Count()\n", done)
+		elapsed := time.Since(start)
+		srv.Close()
+		if (err != nil) != test.wantFail {
+			t.Errorf("%v: got error %v, want error: %v", test.name, err, test.wantFail)
+		}
+		if test.wantErr != nil && err != test.wantErr {
+			t.Errorf("%v: got error %v, want %v", test.name, err, test.wantErr)
+		}
+		if test.wantErr == nil && err == errBatchTimeout {
+			t.Errorf("%v: got %v", test.name, err)
+		}
+		if err == nil && len(response.Results) != 1 {
+			t.Errorf("%v: got %d results, want 1", test.name, len(response.Results))
+		}
+		if err == errBatchTimeout && elapsed >= test.stub.delay {
+			t.Errorf("%v: waited %v for a cancelled request", test.name, elapsed)
+		}
+		if n := atomic.LoadInt64(&s.Nodes[0].inflight); n != 0 {
+			t.Errorf("%v: %d requests still in flight", test.name, n)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	qs := NewQuerySet("test", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_year", rowID=%d))`, [][]int{{1, 2, 3, 4, 5, 6, 7, 8}})
+	opts := RunOptions{Concurrency: 1, BatchSize: 1, Order: "sequential", Balance: "round-robin", Format: "txt"}
+	srv := httptest.NewServer(&pilosaStub{delay: 40 * time.Millisecond})
+	defer srv.Close()
+	s := newTestServer(t, srv.Listener.Addr().String())
+	s.runTimeout = 100 * time.Millisecond
+
+	bench := s.runSumMultiBatch(qs, opts, nil)
+	if !bench.DeadlineExceeded {
+		t.Errorf("deadline not exceeded")
+	}
+	if bench.Completed == 0 || bench.Completed >= qs.iterations {
+		t.Errorf("got %d of %d queries completed, want some", bench.Completed, qs.iterations)
+	}
+	if bench.TimedOut != 1 || len(bench.TimedOutInputs) != 1 {
+		t.Errorf("got %d timed out with inputs %v, want the one in flight", bench.TimedOut, bench.TimedOutInputs)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	const delay, backoff = 20 * time.Millisecond, 50 * time.Millisecond
+	srv := httptest.NewServer(&pilosaStub{fail: 1, delay: delay})
+	defer srv.Close()
+	s := newTestServer(t, srv.Listener.Addr().String())
+	s.errorPolicy = ErrorPolicy{Retries: 1, Backoff: backoff}
+
+	start := time.Now()
+	_, stats, err := s.queryWithRetry(s.Nodes[0], "Count()\n", nil)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both attempts count towards the request time, but the backoff between them
+	// doesn't.
+	if stats.request < 2*delay || stats.request > elapsed-backoff {
+		t.Errorf("got request time %v for two %v requests %v apart in %v", stats.request, delay, backoff, elapsed)
+	}
+	if stats.decode <= 0 || stats.decode >= stats.request {
+		t.Errorf("got decode time %v for request time %v", stats.decode, stats.request)
+	}
+
+	qs := NewQuerySet("test", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_year", rowID=%d))`, [][]int{{1, 2, 3}})
+	bench := s.runSumMultiBatch(qs, RunOptions{Concurrency: 1, BatchSize: 1, Order: "sequential", Balance: "round-robin", Format: "txt"}, nil)
+	if bench.Phases.Request < 3*delay.Seconds() || bench.Phases.Request > bench.Seconds {
+		t.Errorf("got request phase %v for three %v requests in %v seconds", bench.Phases.Request, delay, bench.Seconds)
+	}
+	if bench.Phases.Decode <= 0 || bench.Phases.Decode >= bench.Phases.Request {
+		t.Errorf("got decode phase %v for request phase %v", bench.Phases.Decode, bench.Phases.Request)
+	}
+}
+
+// TestPartRowIDs checks that the p_mfgr, p_category and p_brand1 rows the queries
+// select are those ssbref encodes the SSB parts they filter on as, so golden
+// answers agree with Pilosa's.
+func TestPartRowIDs(t *testing.T) {
+	// brands lists the brand codes prefix01 to prefix40, or those from first to last.
+	brands := func(prefix string, first, last int) []string {
+		var codes []string
+		for b := first; b <= last; b++ {
+			codes = append(codes, fmt.Sprintf("%v%02d", prefix, b))
+		}
+		return codes
+	}
+	var categories []string // MFGR#1 and MFGR#2, by category
+	for _, c := range []string{"11", "12", "13", "14", "15", "21", "22", "23", "24", "25"} {
+		categories = append(categories, "MFGR#"+c+"01")
+	}
+	mfgr := func(p ssbref.Part) int { return p.Mfgr }
+	category := func(p ssbref.Part) int { return p.Category }
+	brand := func(p ssbref.Part) int { return p.Brand }
+	tests := []struct {
+		queries []string
+		frame   string
+		codes   []string
+		encode  func(ssbref.Part) int
+	}{
+		{[]string{"2.1", "2.1r"}, "p_brand1", brands("MFGR#12", 1, 40), brand},
+		{[]string{"2.2"}, "p_brand1", brands("MFGR#22", 21, 28), brand},
+		{[]string{"2.3"}, "p_brand1", []string{"MFGR#2221"}, brand},
+		{[]string{"4.1", "4.1r", "4.1rb"}, "p_mfgr", []string{"MFGR#1101", "MFGR#2101"}, mfgr},
+		{[]string{"4.2", "4.2r"}, "p_category", categories, category},
+		{[]string{"4.3", "4.3r"}, "p_brand1", brands("MFGR#14", 1, 40), brand},
+	}
+	for _, test := range tests {
+		var want []int
+		for _, code := range test.codes {
+			p, err := ssbref.EncodePart(code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, test.encode(p))
+		}
+		for _, qname := range test.queries {
+			qs := getQuerySet(qname)
+			var got []int
+			constant := regexp.MustCompile(`frame="?` + test.frame + `"?, rowID=(\d+)`)
+			for _, m := range constant.FindAllStringSubmatch(qs.Format+qs.setup, -1) {
+				id, _ := strconv.Atoi(m[1])
+				got = append(got, id)
+			}
+			for k, label := range qs.Labels() {
+				if label == test.frame {
+					got = append(got, qs.ArgSets[k]...)
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v: got %v rows %v, want %v for %v", qname, test.frame, got, want, test.codes)
+			}
+		}
+	}
+}
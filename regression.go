@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// regressionMetrics lists the supported RegressionPolicy.Metric values.
+var regressionMetrics = []string{"mean", "p99"}
+
+// parseRegressionPolicy overrides the fields of p with the non-empty metric and
+// tolerance parameters, and validates the result.
+func parseRegressionPolicy(p RegressionPolicy, metric, tolerance string) (RegressionPolicy, error) {
+	if metric != "" {
+		p.Metric = metric
+	}
+	if p.Metric != "mean" && p.Metric != "p99" {
+		return p, fmt.Errorf("invalid metric: %q, expected one of %v", p.Metric, regressionMetrics)
+	}
+	if tolerance != "" {
+		t, err := strconv.ParseFloat(tolerance, 64)
+		if err != nil || t < 0 {
+			return p, fmt.Errorf("invalid tolerance: %q", tolerance)
+		}
+		p.Tolerance = t
+	}
+	return p, nil
+}
+
+// regressionValue is the metric of res that p compares.
+func regressionValue(p RegressionPolicy, res BenchmarkResult) float64 {
+	if p.Metric == "p99" {
+		return res.Latency.P99
+	}
+	return res.Latency.Mean
+}
+
+// compareToBaseline checks res against the baseline run of its query. A run that
+// failed never passes. Differences in settings that make the timings hard to
+// compare are reported as warnings.
+func compareToBaseline(base, res BenchmarkResult, p RegressionPolicy) RegressionCheck {
+	check := RegressionCheck{
+		Query:         res.Name,
+		Run:           res.RunID,
+		Baseline:      base.RunID,
+		Policy:        p,
+		BaselineValue: regressionValue(p, base),
+		Value:         regressionValue(p, res),
+	}
+	if check.BaselineValue > 0 {
+		check.Change = (check.Value - check.BaselineValue) / check.BaselineValue
+	}
+	check.Pass = check.Change <= p.Tolerance
+	if res.Seconds < 0 || res.Aborted || res.DeadlineExceeded {
+		check.Pass = false
+		check.Warnings = append(check.Warnings, "run did not complete")
+	}
+	warn := func(setting string, baseline, run interface{}) {
+		if baseline != run {
+			check.Warnings = append(check.Warnings, fmt.Sprintf("%v differs: baseline %v, run %v", setting, baseline, run))
+		}
+	}
+	warn("cluster", base.Cluster, res.Cluster)
+	warn("concurrency", base.Concurrency, res.Concurrency)
+	warn("batchsize", base.BatchSize, res.BatchSize)
+	warn("precomputed", base.Precomputed, res.Precomputed)
+	warn("columncount", base.ColumnCount, res.ColumnCount)
+	return check
+}
+
+func newGateResult(checks []RegressionCheck) *GateResult {
+	g := &GateResult{Pass: true, Checks: checks}
+	for _, c := range checks {
+		g.Pass = g.Pass && c.Pass
+	}
+	return g
+}
+
+// checkRegression compares bench against the baseline of its query, returning nil
+// if there is no baseline to compare against.
+func (s *Server) checkRegression(bench BenchmarkResult, p RegressionPolicy) *RegressionCheck {
+	if s.store == nil {
+		return nil
+	}
+	base, err := s.store.Baseline(bench.Name)
+	if err != nil {
+		if err != ErrRunNotFound {
+			fmt.Printf("getting baseline for %v: %v\n", bench.Name, err)
+		}
+		return nil
+	}
+	check := compareToBaseline(base.Result, bench, p)
+	if !check.Pass {
+		fmt.Printf("%v regressed: %v %v vs baseline %v (%+.1f%%)\n", bench.Name, p.Metric, check.Value, check.BaselineValue, 100*check.Change)
+	}
+	return &check
+}
+
+// markBaseline makes bench the baseline of its query, if it completed.
+func (s *Server) markBaseline(bench BenchmarkResult) {
+	if s.store == nil || bench.RunID == "" {
+		return
+	}
+	if bench.Seconds < 0 || bench.Aborted || bench.DeadlineExceeded {
+		fmt.Printf("not marking %v as baseline: run did not complete\n", bench.RunID)
+		return
+	}
+	if err := s.store.SetBaseline(bench.Name, bench.RunID); err != nil {
+		fmt.Printf("marking %v as baseline: %v\n", bench.RunID, err)
+	}
+}
+
+// HandleBaselines lists the baseline run of each query.
+func (s *Server) HandleBaselines(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	baselines, err := s.store.Baselines()
+	if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(baselines); err != nil {
+		fmt.Printf("writing baselines to responsewriter: %v", err)
+	}
+}
+
+// HandleSetBaseline makes a stored run the baseline of its query.
+func (s *Server) HandleSetBaseline(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	run, err := s.store.Run(id)
+	if err != nil {
+		writeError(w, storeError(id, err))
+		return
+	}
+	if err := s.store.SetBaseline(run.Result.Name, run.ID); err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(map[string]string{run.Result.Name: run.ID}); err != nil {
+		fmt.Printf("writing baseline to responsewriter: %v", err)
+	}
+}
+
+// errNoBaseline is returned by Store.Gate for a query with no baseline.
+type errNoBaseline string
+
+func (e errNoBaseline) Error() string {
+	return fmt.Sprintf("no baseline for %v", string(e))
+}
+
+// Gate checks the stored runs with the given IDs against the baselines of their
+// queries. With no IDs, it checks the latest run of every query that has a baseline.
+func (st *Store) Gate(ids []string, p RegressionPolicy) (*GateResult, error) {
+	var runs []StoredRun
+	if len(ids) == 0 {
+		baselines, err := st.Baselines()
+		if err != nil {
+			return nil, err
+		}
+		queries := make([]string, 0, len(baselines))
+		for query := range baselines {
+			queries = append(queries, query)
+		}
+		sort.Strings(queries)
+		for _, query := range queries {
+			latest, err := st.ListRuns(RunFilter{Query: query, Limit: 1})
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, latest...)
+		}
+	}
+	for _, id := range ids {
+		run, err := st.Run(id)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", id, err)
+		}
+		runs = append(runs, run)
+	}
+
+	checks := []RegressionCheck{}
+	for _, run := range runs {
+		base, err := st.Baseline(run.Result.Name)
+		if err == ErrRunNotFound {
+			return nil, errNoBaseline(run.Result.Name)
+		} else if err != nil {
+			return nil, fmt.Errorf("baseline for %v: %v", run.Result.Name, err)
+		}
+		checks = append(checks, compareToBaseline(base.Result, run.Result, p))
+	}
+	return newGateResult(checks), nil
+}
+
+// HandleGate checks stored runs against their baselines: the run in the path,
+// e.g. /runs/2.1-1510000000/gate, or those in the runs parameter of /gate, which
+// defaults to the latest run of every query with a baseline. The metric and
+// tolerance parameters override the server's regression policy.
+func (s *Server) HandleGate(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	params := r.URL.Query()
+	policy, err := parseRegressionPolicy(s.gate, params.Get("metric"), params.Get("tolerance"))
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+	var ids []string
+	if id, ok := mux.Vars(r)["id"]; ok {
+		ids = []string{id}
+	} else if v := params.Get("runs"); v != "" {
+		ids = strings.Split(v, ",")
+	}
+	for _, id := range ids {
+		if _, err := s.store.Run(id); err != nil {
+			writeError(w, storeError(id, err))
+			return
+		}
+	}
+	gate, err := s.store.Gate(ids, policy)
+	if _, ok := err.(errNoBaseline); ok {
+		writeError(w, APIError{Status: http.StatusNotFound, Code: codeNoBaseline, Message: err.Error()})
+		return
+	} else if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(gate); err != nil {
+		fmt.Printf("writing gate: %v to responsewriter: %v", gate, err)
+	}
+}
+
+// gateCommand implements `main gate [run...]`, checking stored runs against
+// the baselines of their queries with Store.Gate, through the server at api if it
+// is running. It prints the verdict as JSON and returns 0 if every check passed,
+// 1 if any failed and 2 on error.
+func gateCommand(storePath string, api *client.Client, p RegressionPolicy, args []string) int {
+	var gate interface{}
+	var pass bool
+	if api != nil {
+		g, err := api.Gate(args, p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		gate, pass = g, g.Pass
+	} else {
+		store, err := OpenStore(storePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer store.Close()
+		g, err := store.Gate(args, p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		gate, pass = g, g.Pass
+	}
+	if err := printJSON(gate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !pass {
+		return 1
+	}
+	return 0
+}
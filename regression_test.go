@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareToBaseline(t *testing.T) {
+	base := BenchmarkResult{RunID: "1.1-a", Name: "1.1", Seconds: 10, Cluster: "default", Concurrency: 8, BatchSize: 100,
+		Latency: LatencyStats{Mean: 1, P99: 2}}
+	mean := RegressionPolicy{Metric: "mean", Tolerance: 0.1}
+	p99 := RegressionPolicy{Metric: "p99", Tolerance: 0.1}
+	tests := []struct {
+		name         string
+		base         BenchmarkResult
+		change       func(res *BenchmarkResult)
+		policy       RegressionPolicy
+		wantChange   float64
+		wantPass     bool
+		wantWarnings []string
+	}{
+		{"same", base, func(res *BenchmarkResult) {}, mean, 0, true, nil},
+		{"faster", base, func(res *BenchmarkResult) { res.Latency.Mean = 0.5 }, mean, -0.5, true, nil},
+		{"within tolerance", base, func(res *BenchmarkResult) { res.Latency.Mean = 1.05 }, mean, 0.05, true, nil},
+		{"regressed", base, func(res *BenchmarkResult) { res.Latency.Mean = 1.5 }, mean, 0.5, false, nil},
+		{"p99", base, func(res *BenchmarkResult) { res.Latency.Mean, res.Latency.P99 = 0.5, 3 }, p99, 0.5, false, nil},
+		{"zero baseline", BenchmarkResult{RunID: "1.1-a", Name: "1.1", Cluster: "default", Concurrency: 8, BatchSize: 100},
+			func(res *BenchmarkResult) {}, mean, 0, true, nil},
+		{"failed", base, func(res *BenchmarkResult) { res.Seconds = -1 }, mean, 0, false, []string{"run did not complete"}},
+		{"aborted", base, func(res *BenchmarkResult) { res.Aborted = true }, mean, 0, false, []string{"run did not complete"}},
+		{"deadline exceeded", base, func(res *BenchmarkResult) { res.DeadlineExceeded = true }, mean, 0, false, []string{"run did not complete"}},
+		{"different settings", base, func(res *BenchmarkResult) { res.Cluster, res.Concurrency = "large", 16 }, mean, 0, true,
+			[]string{"cluster differs: baseline default, run large", "concurrency differs: baseline 8, run 16"}},
+	}
+	for _, test := range tests {
+		res := test.base
+		res.RunID = "1.1-b"
+		test.change(&res)
+		check := compareToBaseline(test.base, res, test.policy)
+		if check.Query != "1.1" || check.Run != "1.1-b" || check.Baseline != "1.1-a" || check.Policy != test.policy {
+			t.Errorf("%v: got check of %v run %v against %v with %+v", test.name, check.Query, check.Run, check.Baseline, check.Policy)
+		}
+		if math.Abs(check.Change-test.wantChange) > 1e-9 || check.Pass != test.wantPass {
+			t.Errorf("%v: got change %v, pass %v, want %v, %v", test.name, check.Change, check.Pass, test.wantChange, test.wantPass)
+		}
+		if !reflect.DeepEqual(check.Warnings, test.wantWarnings) {
+			t.Errorf("%v: got warnings %q, want %q", test.name, check.Warnings, test.wantWarnings)
+		}
+	}
+}
+
+func TestStoreGate(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	start := time.Unix(1510000000, 0)
+	for i, run := range []struct {
+		id   string
+		mean float64
+	}{
+		{"1.1-a", 1}, {"1.1-b", 1.5}, {"1.1-c", 1.05},
+		{"2.1-a", 2}, {"2.1-b", 1},
+		{"3.1-a", 1},
+	} {
+		res := BenchmarkResult{RunID: run.id, Name: run.id[:3], Seconds: 1, Latency: LatencyStats{Mean: run.mean}}
+		if err := store.SaveRun(StoredRun{ID: run.id, Time: start.Add(time.Duration(i) * time.Minute), Result: res}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"1.1-a", "2.1-a"} {
+		if err := store.SetBaseline(id[:3], id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := RegressionPolicy{Metric: "mean", Tolerance: 0.1}
+	tests := []struct {
+		name     string
+		ids      []string
+		wantRuns []string
+		wantPass bool
+		wantErr  string
+	}{
+		{"latest", nil, []string{"1.1-c", "2.1-b"}, true, ""},
+		{"one run", []string{"1.1-b"}, []string{"1.1-b"}, false, ""},
+		{"several runs", []string{"2.1-b", "1.1-c"}, []string{"2.1-b", "1.1-c"}, true, ""},
+		{"any regression fails", []string{"1.1-c", "1.1-b"}, []string{"1.1-c", "1.1-b"}, false, ""},
+		{"unknown run", []string{"1.1-z"}, nil, false, "1.1-z: run not found"},
+		{"no baseline", []string{"3.1-a"}, nil, false, "no baseline for 3.1"},
+	}
+	for _, test := range tests {
+		gate, err := store.Gate(test.ids, policy)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%v: got error %v, want %v", test.name, err, test.wantErr)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		var runs []string
+		for _, check := range gate.Checks {
+			runs = append(runs, check.Run)
+		}
+		if !reflect.DeepEqual(runs, test.wantRuns) || gate.Pass != test.wantPass {
+			t.Errorf("%v: got runs %v, pass %v, want %v, %v", test.name, runs, gate.Pass, test.wantRuns, test.wantPass)
+		}
+	}
+	if _, err := store.Gate([]string{"3.1-a"}, policy); err == nil {
+		t.Errorf("got no error for a run without a baseline")
+	} else if _, ok := err.(errNoBaseline); !ok {
+		t.Errorf("got %T for a run without a baseline, want errNoBaseline", err)
+	}
+
+	empty, err := OpenStore(filepath.Join(t.TempDir(), "empty.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Close()
+	if gate, err := empty.Gate(nil, policy); err != nil || !gate.Pass || gate.Checks == nil || len(gate.Checks) != 0 {
+		t.Errorf("empty store: got %+v, %v, want a passing gate with no checks", gate, err)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// reportRun is a stored run with the values the report shows for it.
+type reportRun struct {
+	StoredRun
+	QPS       float64
+	Check     *RegressionCheck // against the current baseline of its query
+	Verified  string
+	LatencyMS float64
+	P99MS     float64
+}
+
+// reportQuery groups the runs of one query in a report.
+type reportQuery struct {
+	Name        string
+	Labels      []string
+	Definitions []reportDefinition // newest first
+	Heatmap     template.HTML      // seconds by concurrency and batch size, if it was run with several
+}
+
+// reportDefinition is a version of a query that runs in the report executed.
+type reportDefinition struct {
+	Runs     []string // IDs of the runs that executed it
+	PQL      string
+	Setup    string
+	Teardown string
+}
+
+// queryDefinitions groups the runs of a query by the definition they recorded
+// executing.
+func queryDefinitions(runs []reportRun) []reportDefinition {
+	var defs []reportDefinition
+	byHash := make(map[string]int)
+	for _, r := range runs {
+		meta := r.Result.Meta
+		if n, ok := byHash[meta.QueryHash]; ok {
+			defs[n].Runs = append(defs[n].Runs, r.ID)
+			continue
+		}
+		byHash[meta.QueryHash] = len(defs)
+		defs = append(defs, reportDefinition{Runs: []string{r.ID}, PQL: meta.PQL, Setup: meta.Setup, Teardown: meta.Teardown})
+	}
+	return defs
+}
+
+// environment is where a run executed, from its metadata.
+type environment struct {
+	Hostname      string
+	GoVersion     string
+	DemoVersion   string
+	PilosaVersion string
+	PilosaAddr    string
+	Index         string
+}
+
+func runEnvironment(meta RunMetadata) environment {
+	return environment{
+		Hostname:      meta.Hostname,
+		GoVersion:     meta.GoVersion,
+		DemoVersion:   meta.DemoVersion,
+		PilosaVersion: meta.PilosaVersion,
+		PilosaAddr:    meta.PilosaAddr,
+		Index:         meta.Index,
+	}
+}
+
+type reportData struct {
+	Generated    time.Time
+	Runs         []reportRun
+	Queries      []reportQuery
+	Environments []environment
+	Policy       RegressionPolicy
+	LatencyChart template.HTML
+	BaselineBars template.HTML
+}
+
+// svgEscape escapes text for inclusion in generated SVG.
+func svgEscape(s string) string {
+	return template.HTMLEscapeString(s)
+}
+
+// latencyChart draws a bar of the mean batch latency of each run, with a tick at
+// its p99.
+func latencyChart(runs []reportRun) template.HTML {
+	const left, width, row = 220, 480, 20
+	max := 0.0
+	for _, r := range runs {
+		max = math.Max(max, math.Max(r.LatencyMS, r.P99MS))
+	}
+	if max == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, left+width+120, len(runs)*row+30)
+	for i, r := range runs {
+		y := i * row
+		mean := r.LatencyMS / max * width
+		p99 := r.P99MS / max * width
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s c=%d b=%d</text>`, y+14, svgEscape(r.Result.Name), r.Result.Concurrency, r.Result.BatchSize)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="#3498db"><title>%s</title></rect>`, left, y+3, math.Max(mean, 1), row-6, svgEscape(r.ID))
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#c0392b" stroke-width="2"/>`, left+p99, y+1, left+p99, y+row-1)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%.2f / %.2f ms</text>`, left+math.Max(mean, p99)+6, y+14, r.LatencyMS, r.P99MS)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">bars: mean batch latency, red ticks: p99</text>`, left, len(runs)*row+20)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// baselineChart draws pairs of bars comparing each checked run with its baseline.
+func baselineChart(runs []reportRun) template.HTML {
+	var checked []reportRun
+	max := 0.0
+	for _, r := range runs {
+		if r.Check != nil {
+			checked = append(checked, r)
+			max = math.Max(max, math.Max(r.Check.Value, r.Check.BaselineValue))
+		}
+	}
+	if len(checked) == 0 || max == 0 {
+		return ""
+	}
+	const left, width, row = 220, 480, 34
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, left+width+160, len(checked)*row+30)
+	for i, r := range checked {
+		y := i * row
+		color := "#27ae60"
+		if !r.Check.Pass {
+			color = "#c0392b"
+		}
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, y+18, svgEscape(r.Result.Name+" "+r.ID))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="12" fill="#95a5a6"/>`, left, y+2, math.Max(r.Check.BaselineValue/max*width, 1))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="12" fill="%s"/>`, left, y+16, math.Max(r.Check.Value/max*width, 1), color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%+.1f%%</text>`, left+width+10, y+18, 100*r.Check.Change)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">grey: baseline, colored: run</text>`, left, len(checked)*row+20)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// heatmap draws the seconds of the latest run of each concurrency and batch size
+// combination, from green (fastest) to red (slowest).
+func heatmap(runs []reportRun) template.HTML {
+	type setting struct{ c, b int }
+	seconds := make(map[setting]float64)
+	var cs, bs []int
+	seenC, seenB := make(map[int]bool), make(map[int]bool)
+	for _, r := range runs { // newest first, so the first run of a setting wins
+		if r.Result.Seconds <= 0 {
+			continue
+		}
+		s := setting{r.Result.Concurrency, r.Result.BatchSize}
+		if _, ok := seconds[s]; ok {
+			continue
+		}
+		seconds[s] = r.Result.Seconds
+		if !seenC[s.c] {
+			cs = append(cs, s.c)
+			seenC[s.c] = true
+		}
+		if !seenB[s.b] {
+			bs = append(bs, s.b)
+			seenB[s.b] = true
+		}
+	}
+	if len(seconds) < 2 {
+		return ""
+	}
+	sort.Ints(cs)
+	sort.Ints(bs)
+	min, max := math.Inf(1), 0.0
+	for _, v := range seconds {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+	const cell, left, top = 60, 90, 30
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, left+len(bs)*cell+10, top+len(cs)*cell+10)
+	fmt.Fprintf(&b, `<text x="0" y="14">concurrency \ batch size</text>`)
+	for j, bsize := range bs {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%d</text>`, left+j*cell+cell/2, top-4, bsize)
+	}
+	for i, c := range cs {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%d</text>`, left-6, top+i*cell+cell/2+4, c)
+		for j, bsize := range bs {
+			v, ok := seconds[setting{c, bsize}]
+			if !ok {
+				continue
+			}
+			f := 0.0
+			if max > min {
+				f = (v - min) / (max - min)
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="rgb(%d,%d,80)"/>`, left+j*cell, top+i*cell, cell-2, cell-2, int(60+180*f), int(200-140*f))
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" fill="#fff">%.2fs</text>`, left+j*cell+cell/2, top+i*cell+cell/2+4, v)
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// newReport gathers the stored runs with the given IDs, newest first, into a report.
+func newReport(st *Store, ids []string, policy RegressionPolicy) (*reportData, error) {
+	data := &reportData{Generated: time.Now(), Policy: policy}
+	for _, id := range ids {
+		run, err := st.Run(id)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", id, err)
+		}
+		rr := reportRun{
+			StoredRun: run,
+			LatencyMS: 1000 * run.Result.Latency.Mean,
+			P99MS:     1000 * run.Result.Latency.P99,
+		}
+		if run.Result.Seconds > 0 {
+			rr.QPS = float64(run.Result.Iterations) / run.Result.Seconds
+		}
+		if base, err := st.Baseline(run.Result.Name); err == nil && base.ID != run.ID {
+			check := compareToBaseline(base.Result, run.Result, policy)
+			rr.Check = &check
+		}
+		if v := run.Result.Verification; v != nil {
+			rr.Verified = "wrong"
+			if v.Pass {
+				rr.Verified = "ok"
+			}
+		}
+		data.Runs = append(data.Runs, rr)
+	}
+	sort.SliceStable(data.Runs, func(i, j int) bool { return data.Runs[i].Time.After(data.Runs[j].Time) })
+
+	byQuery := make(map[string][]reportRun)
+	var names []string
+	envs := make(map[environment]bool)
+	for _, r := range data.Runs {
+		if byQuery[r.Result.Name] == nil {
+			names = append(names, r.Result.Name)
+		}
+		byQuery[r.Result.Name] = append(byQuery[r.Result.Name], r)
+		if env := runEnvironment(r.Result.Meta); !envs[env] {
+			envs[env] = true
+			data.Environments = append(data.Environments, env)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		qs := getQuerySet(name)
+		data.Queries = append(data.Queries, reportQuery{
+			Name:        name,
+			Labels:      qs.Labels(),
+			Definitions: queryDefinitions(byQuery[name]),
+			Heatmap:     heatmap(byQuery[name]),
+		})
+	}
+	data.LatencyChart = latencyChart(data.Runs)
+	data.BaselineBars = baselineChart(data.Runs)
+	return data, nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct":  func(f float64) string { return fmt.Sprintf("%+.1f%%", 100*f) },
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Pilosa SSB benchmark report</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #222; }
+table { border-collapse: collapse; margin: 8px 0 20px; font-size: 0.9em; }
+th, td { border: 1px solid #ddd; padding: 3px 8px; text-align: right; }
+th { background: #f0f0f0; }
+td.l { text-align: left; }
+pre { background: #f6f6f6; padding: 8px; font-size: 0.85em; }
+svg text { font-size: 11px; fill: #444; }
+.pass { color: #27ae60; }
+.fail { color: #c0392b; }
+</style>
+</head>
+<body>
+<h1>Pilosa SSB benchmark report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} from {{len .Runs}} runs.</p>
+
+<h2>Environment</h2>
+<table>
+<tr><th>host</th><th>Go</th><th>demo</th><th>Pilosa version</th><th>Pilosa</th><th>index</th></tr>
+{{range .Environments}}<tr><td class="l">{{.Hostname}}</td><td class="l">{{.GoVersion}}</td><td class="l">{{.DemoVersion}}</td><td class="l">{{.PilosaVersion}}</td><td class="l">{{.PilosaAddr}}</td><td class="l">{{.Index}}</td></tr>
+{{end}}</table>
+
+<h2>Runs</h2>
+<table>
+<tr><th>run</th><th>time</th><th>query</th><th>cluster</th><th>concurrency</th><th>batch size</th><th>seconds</th><th>queries/s</th><th>mean ms</th><th>p99 ms</th><th>completed</th><th>failed</th><th>timed out</th><th>results</th><th>vs baseline</th></tr>
+{{range .Runs}}<tr>
+<td class="l">{{.ID}}</td><td class="l">{{.Time.Format "2006-01-02 15:04:05"}}</td><td class="l">{{.Result.Name}}</td><td class="l">{{.Result.Cluster}}</td>
+<td>{{.Result.Concurrency}}</td><td>{{.Result.BatchSize}}</td><td>{{printf "%.3f" .Result.Seconds}}</td><td>{{printf "%.1f" .QPS}}</td>
+<td>{{printf "%.2f" .LatencyMS}}</td><td>{{printf "%.2f" .P99MS}}</td>
+<td>{{.Result.Completed}}</td><td>{{.Result.Failed}}</td><td>{{.Result.TimedOut}}</td>
+<td class="{{if eq .Verified "ok"}}pass{{else if eq .Verified "wrong"}}fail{{end}}">{{.Verified}}</td>
+<td class="{{if .Check}}{{if .Check.Pass}}pass{{else}}fail{{end}}{{end}}">{{if .Check}}{{pct .Check.Change}}{{end}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Latency per query</h2>
+{{.LatencyChart}}
+
+{{if .BaselineBars}}<h2>Comparison against baseline</h2>
+<p>{{.Policy.Metric}} batch latency, tolerance {{pct .Policy.Tolerance}}.</p>
+{{.BaselineBars}}
+{{end}}
+
+<h2>Queries</h2>
+{{range .Queries}}<h3>{{.Name}}</h3>
+<p>Grouped by {{join .Labels ", "}}.</p>
+{{if .Heatmap}}<p>Seconds by concurrency and batch size:</p>
+{{.Heatmap}}
+{{end}}{{$several := gt (len .Definitions) 1}}{{range .Definitions}}{{if $several}}<p>Executed by {{join .Runs ", "}}:</p>
+{{end}}{{if .Setup}}<p>Setup:</p>
+<pre>{{.Setup}}</pre>
+{{end}}<pre>{{.PQL}}</pre>
+{{if .Teardown}}<p>Teardown:</p>
+<pre>{{.Teardown}}</pre>
+{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
+
+// writeReport writes a self-contained HTML report of the stored runs with the given IDs.
+func writeReport(w io.Writer, st *Store, ids []string, policy RegressionPolicy) error {
+	data, err := newReport(st, ids, policy)
+	if err != nil {
+		return err
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// HandleReport renders an HTML report of the runs listed in the runs parameter,
+// or else of the runs matching the /runs filter parameters, e.g.
+// /report?runs=2.1-1510000000,2.2-1510000060 or /report?since=2017-11-01. The
+// metric and tolerance parameters override the server's regression policy.
+func (s *Server) HandleReport(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	params := r.URL.Query()
+	policy, err := parseRegressionPolicy(s.gate, params.Get("metric"), params.Get("tolerance"))
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+	var ids []string
+	if v := params.Get("runs"); v != "" {
+		ids = strings.Split(v, ",")
+	} else {
+		f, err := runFilter(r)
+		if err != nil {
+			writeError(w, invalidParameter(err))
+			return
+		}
+		runs, err := s.store.ListRuns(f)
+		if err != nil {
+			writeError(w, internalError(err))
+			return
+		}
+		for _, run := range runs {
+			ids = append(ids, run.ID)
+		}
+	}
+	for _, id := range ids {
+		if _, err := s.store.Run(id); err != nil {
+			writeError(w, storeError(id, err))
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeReport(&buf, s.store, ids, policy); err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		fmt.Printf("writing report to responsewriter: %v", err)
+	}
+}
+
+// reportCommand implements `main report <run>...`, writing an HTML report of the
+// given stored runs to stdout, through the server at api if it is running.
+func reportCommand(storePath string, api *client.Client, policy RegressionPolicy, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: main [--store path] [--server host:port] report <run>... > report.html")
+		return 2
+	}
+	if api != nil {
+		report, err := api.Report(args, policy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if _, err := os.Stdout.Write(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return 0
+	}
+	store, err := OpenStore(storePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer store.Close()
+	if err := writeReport(os.Stdout, store, args, policy); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
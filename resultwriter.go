@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+)
+
+// ResultWriter writes the result rows of a run to its results file.
+type ResultWriter interface {
+	// WriteRow writes the inputs of a query together with its result.
+	WriteRow(row ResultRow) error
+	// WriteTimeout records the inputs of a query whose batch timed out.
+	WriteTimeout(inputs []int) error
+	// Close flushes buffered rows. It does not close the underlying writer.
+	Close() error
+}
+
+// resultFormats maps the supported RunOptions.Format values to file extensions.
+var resultFormats = map[string]string{
+	"txt":   ".txt",
+	"csv":   ".csv",
+	"jsonl": ".jsonl",
+	"arrow": ".arrows",
+}
+
+// newResultWriter returns a ResultWriter for format, naming the input columns
+// after labels.
+func newResultWriter(format string, w io.Writer, labels []string) (ResultWriter, error) {
+	switch format {
+	case "txt":
+		return &textWriter{w: w}, nil
+	case "csv":
+		cw := &csvWriter{w: csv.NewWriter(w), labels: labels}
+		return cw, cw.w.Write(append(append([]string{}, labels...), "value", "timeout"))
+	case "jsonl":
+		return &jsonlWriter{enc: json.NewEncoder(w), labels: labels}, nil
+	case "arrow":
+		return newArrowWriter(w, labels), nil
+	}
+	return nil, fmt.Errorf("unknown result format: %q", format)
+}
+
+// textWriter writes the original "<value> [inputs]" lines, or "timeout [inputs]".
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) WriteRow(row ResultRow) error {
+	_, err := fmt.Fprintf(t.w, "%v %v\n", row.Value, row.Inputs)
+	return err
+}
+
+func (t *textWriter) WriteTimeout(inputs []int) error {
+	_, err := fmt.Fprintf(t.w, "%v %v\n", "timeout", inputs)
+	return err
+}
+
+func (t *textWriter) Close() error { return nil }
+
+// csvWriter writes a header of the input labels, "value" and "timeout", then one
+// record per query. Timed out queries have an empty value.
+type csvWriter struct {
+	w      *csv.Writer
+	labels []string
+}
+
+func (c *csvWriter) record(inputs []int, value string, timeout bool) error {
+	record := make([]string, 0, len(inputs)+2)
+	for _, in := range inputs {
+		record = append(record, strconv.Itoa(in))
+	}
+	return c.w.Write(append(record, value, strconv.FormatBool(timeout)))
+}
+
+func (c *csvWriter) WriteRow(row ResultRow) error {
+	return c.record(row.Inputs, strconv.Itoa(row.Value), false)
+}
+
+func (c *csvWriter) WriteTimeout(inputs []int) error {
+	return c.record(inputs, "", true)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes one JSON object per query, keyed by the input labels, e.g.
+// {"p_brand1":40,"lo_year":1992,"value":1234}. Timed out queries have
+// "timeout":true and no value.
+type jsonlWriter struct {
+	enc    *json.Encoder
+	labels []string
+}
+
+func (j *jsonlWriter) object(inputs []int) map[string]interface{} {
+	obj := make(map[string]interface{}, len(inputs)+1)
+	for n, in := range inputs {
+		obj[j.labels[n]] = in
+	}
+	return obj
+}
+
+func (j *jsonlWriter) WriteRow(row ResultRow) error {
+	obj := j.object(row.Inputs)
+	obj["value"] = row.Value
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlWriter) WriteTimeout(inputs []int) error {
+	obj := j.object(inputs)
+	obj["timeout"] = true
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlWriter) Close() error { return nil }
+
+// arrowWriter buffers rows into a single record batch with an int64 column per
+// input and a nullable int64 "value" column, null for timed out queries, and
+// writes it as an Arrow IPC stream on Close.
+type arrowWriter struct {
+	w       io.Writer
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+}
+
+func newArrowWriter(w io.Writer, labels []string) *arrowWriter {
+	fields := make([]arrow.Field, 0, len(labels)+1)
+	for _, label := range labels {
+		fields = append(fields, arrow.Field{Name: label, Type: arrow.PrimitiveTypes.Int64})
+	}
+	fields = append(fields, arrow.Field{Name: "value", Type: arrow.PrimitiveTypes.Int64, Nullable: true})
+	schema := arrow.NewSchema(fields, nil)
+	return &arrowWriter{w: w, schema: schema, builder: array.NewRecordBuilder(memory.DefaultAllocator, schema)}
+}
+
+func (a *arrowWriter) appendInputs(inputs []int) {
+	for n, in := range inputs {
+		a.builder.Field(n).(*array.Int64Builder).Append(int64(in))
+	}
+}
+
+func (a *arrowWriter) WriteRow(row ResultRow) error {
+	a.appendInputs(row.Inputs)
+	a.builder.Field(len(row.Inputs)).(*array.Int64Builder).Append(int64(row.Value))
+	return nil
+}
+
+func (a *arrowWriter) WriteTimeout(inputs []int) error {
+	a.appendInputs(inputs)
+	a.builder.Field(len(inputs)).(*array.Int64Builder).AppendNull()
+	return nil
+}
+
+func (a *arrowWriter) Close() error {
+	defer a.builder.Release()
+	rec := a.builder.NewRecord()
+	defer rec.Release()
+	w := ipc.NewWriter(a.w, ipc.WithSchema(a.schema))
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pilosa/demo-ssb/client"
+)
+
+// QueuedRun is a benchmark request waiting for, or holding, a slot in a RunQueue.
+type QueuedRun struct {
+	client.QueuedRun
+
+	done       *int64 // completed queries of Query, accessed atomically; see SetDone
+	overlapped bool   // another run overlapped Query; Overlapped covers the whole request
+}
+
+// RunQueue admits benchmark runs in arrival order. An exclusive queue runs one at a
+// time so concurrent requests can't skew each other's timings; otherwise runs start
+// immediately and are only marked as overlapping.
+type RunQueue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	exclusive bool
+	nextID    uint64
+	running   []*QueuedRun
+	waiting   []*QueuedRun
+}
+
+func NewRunQueue(exclusive bool) *RunQueue {
+	q := &RunQueue{exclusive: exclusive}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Acquire blocks until the named run may start, or until ctx is done, such as when
+// the client of the request has gone away. It then leaves the queue and returns
+// ctx.Err().
+func (q *RunQueue) Acquire(ctx context.Context, name string) (*QueuedRun, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	run := &QueuedRun{QueuedRun: client.QueuedRun{ID: q.nextID, Name: name, Queued: time.Now()}}
+	q.waiting = append(q.waiting, run)
+
+	// A sync.Cond can't wait on a channel, so wake the waiters when ctx is done.
+	acquired := make(chan struct{})
+	defer close(acquired)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.cond.Broadcast()
+			q.mu.Unlock()
+		case <-acquired:
+		}
+	}()
+
+	for q.waiting[0] != run || (q.exclusive && len(q.running) > 0) {
+		if err := ctx.Err(); err != nil {
+			for i, r := range q.waiting {
+				if r == run {
+					q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+					break
+				}
+			}
+			q.cond.Broadcast()
+			return nil, err
+		}
+		q.cond.Wait()
+	}
+	q.waiting = q.waiting[1:]
+	q.start(run)
+	return run, nil
+}
+
+// TryAcquire starts the named run if it can start right away, without waiting
+// behind other runs. Otherwise it returns false.
+func (q *RunQueue) TryAcquire(name string) (*QueuedRun, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) > 0 || (q.exclusive && len(q.running) > 0) {
+		return nil, false
+	}
+	q.nextID++
+	run := &QueuedRun{QueuedRun: client.QueuedRun{ID: q.nextID, Name: name, Queued: time.Now()}}
+	q.start(run)
+	return run, true
+}
+
+// start moves run to the running list. q.mu must be held.
+func (q *RunQueue) start(run *QueuedRun) {
+	run.Started = time.Now()
+	for _, other := range q.running {
+		other.Overlapped, other.overlapped = true, true
+		run.Overlapped, run.overlapped = true, true
+	}
+	q.running = append(q.running, run)
+	// In parallel mode the next waiter can start right away.
+	q.cond.Broadcast()
+}
+
+// Release ends a run and reports whether any other run overlapped with it.
+func (q *RunQueue) Release(run *QueuedRun) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, r := range q.running {
+		if r == run {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+			break
+		}
+	}
+	q.cond.Broadcast()
+	return run.Overlapped
+}
+
+// Overlapped reports whether any other run has overlapped with the query of run
+// last passed to Progress, so that each query of a suite or grid is flagged on its
+// own. It is false for a nil run.
+func (q *RunQueue) Overlapped(run *QueuedRun) bool {
+	if run == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return run.overlapped
+}
+
+// Progress records that query, of total queries, has started in run. The query
+// overlaps another run if one is still running.
+func (q *RunQueue) Progress(run *QueuedRun, query string, total int) {
+	if run == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	run.Query, run.Total = query, total
+	run.done = new(int64)
+	run.overlapped = len(q.running) > 1
+}
+
+// SetDone records that done queries of the query last passed to Progress have
+// completed. It is called for every result of a run, so it updates a counter
+// instead of taking the queue's lock, and must be called from the goroutine that
+// called Progress.
+func (run *QueuedRun) SetDone(done int) {
+	if run == nil || run.done == nil {
+		return
+	}
+	atomic.StoreInt64(run.done, int64(done))
+}
+
+// snapshot returns a copy of run with Done read from its counter.
+func (run *QueuedRun) snapshot() client.QueuedRun {
+	r := run.QueuedRun
+	if run.done != nil {
+		r.Done = int(atomic.LoadInt64(run.done))
+	}
+	return r
+}
+
+// State returns a snapshot of the queue.
+func (q *RunQueue) State() QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state := QueueState{
+		Exclusive: q.exclusive,
+		Running:   make([]client.QueuedRun, 0, len(q.running)),
+		Waiting:   make([]client.QueuedRun, 0, len(q.waiting)),
+	}
+	for _, r := range q.running {
+		state.Running = append(state.Running, r.snapshot())
+	}
+	for _, r := range q.waiting {
+		state.Waiting = append(state.Waiting, r.snapshot())
+	}
+	return state
+}
+
+func (s *Server) HandleQueue(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(s.queue.State()); err != nil {
+		fmt.Printf("writing queue state to responsewriter: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunQueueOverlapped(t *testing.T) {
+	type step struct {
+		op   string // "start", "progress", "check" or "release"
+		run  string
+		want bool // Overlapped after a check, or the result of a release
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"alone", []step{
+			{"start", "a", false}, {"progress", "a", false}, {"check", "a", false},
+			{"progress", "a", false}, {"check", "a", false}, {"release", "a", false},
+		}},
+		{"concurrent", []step{
+			{"start", "a", false}, {"start", "b", false},
+			{"progress", "a", false}, {"check", "a", true}, {"progress", "b", false}, {"check", "b", true},
+			{"release", "b", true}, {"release", "a", true},
+		}},
+		{"later query", []step{
+			{"start", "a", false}, {"progress", "a", false}, {"check", "a", false},
+			{"start", "b", false}, {"check", "a", true}, {"release", "b", true},
+			{"progress", "a", false}, {"check", "a", false}, {"release", "a", true},
+		}},
+		{"started during another run", []step{
+			{"start", "a", false}, {"start", "b", false}, {"progress", "b", false}, {"check", "b", true},
+			{"release", "a", true}, {"progress", "b", false}, {"check", "b", false}, {"release", "b", true},
+		}},
+	}
+	for _, test := range tests {
+		q := NewRunQueue(false)
+		runs := make(map[string]*QueuedRun)
+		for n, step := range test.steps {
+			run := runs[step.run]
+			switch step.op {
+			case "start":
+				var ok bool
+				if runs[step.run], ok = q.TryAcquire(step.run); !ok {
+					t.Fatalf("%v: step %d: %v didn't start", test.name, n, step.run)
+				}
+			case "progress":
+				q.Progress(run, "q", 10)
+			case "check":
+				if got := q.Overlapped(run); got != step.want {
+					t.Errorf("%v: step %d: %v overlapped: %v, want %v", test.name, n, step.run, got, step.want)
+				}
+			case "release":
+				if got := q.Release(run); got != step.want {
+					t.Errorf("%v: step %d: release %v: %v, want %v", test.name, n, step.run, got, step.want)
+				}
+			}
+		}
+	}
+	if NewRunQueue(false).Overlapped(nil) {
+		t.Errorf("nil run overlapped")
+	}
+}
+
+func TestRunQueueExclusive(t *testing.T) {
+	q := NewRunQueue(true)
+	a, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.TryAcquire("b"); ok {
+		t.Errorf("started b while a is running")
+	}
+
+	// A waiter whose client has gone away leaves the queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	gone := make(chan error)
+	go func() {
+		_, err := q.Acquire(ctx, "gone")
+		gone <- err
+	}()
+	started := make(chan string, 2)
+	for _, name := range []string{"b", "c"} {
+		waitForQueue(t, q, len(q.State().Waiting)+1)
+		go func(name string) {
+			run, err := q.Acquire(context.Background(), name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			started <- name
+			time.Sleep(10 * time.Millisecond)
+			q.Release(run)
+		}(name)
+	}
+	waitForQueue(t, q, 3)
+	cancel()
+	if err := <-gone; err != context.Canceled {
+		t.Errorf("cancelled waiter: got %v, want %v", err, context.Canceled)
+	}
+	if state := q.State(); len(state.Waiting) != 2 || state.Waiting[0].Name != "b" || state.Waiting[1].Name != "c" {
+		t.Errorf("got waiting %+v, want b, c", state.Waiting)
+	}
+
+	if q.Release(a) {
+		t.Errorf("a overlapped in an exclusive queue")
+	}
+	for _, want := range []string{"b", "c"} {
+		if got := <-started; got != want {
+			t.Errorf("started %v, want %v", got, want)
+		}
+	}
+}
+
+// waitForQueue waits until n runs are waiting in q.
+func waitForQueue(t *testing.T, q *RunQueue, n int) {
+	for start := time.Now(); len(q.State().Waiting) < n; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("%d runs waiting, want %d", len(q.State().Waiting), n)
+		}
+	}
+}
+
+func TestRunQueueProgress(t *testing.T) {
+	q := NewRunQueue(true)
+	run, _ := q.TryAcquire("/suite")
+	q.Progress(run, "1.1", 100)
+	run.SetDone(40)
+	state := q.State()
+	if len(state.Running) != 1 {
+		t.Fatalf("got %d running, want 1", len(state.Running))
+	}
+	if got := state.Running[0]; got.Query != "1.1" || got.Done != 40 || got.Total != 100 {
+		t.Errorf("got progress %v %d/%d, want 1.1 40/100", got.Query, got.Done, got.Total)
+	}
+	q.Progress(run, "1.2", 50)
+	if got := q.State().Running[0]; got.Query != "1.2" || got.Done != 0 || got.Total != 50 {
+		t.Errorf("got progress %v %d/%d, want 1.2 0/50", got.Query, got.Done, got.Total)
+	}
+	var none *QueuedRun
+	none.SetDone(1)
+	q.Progress(none, "1.1", 1)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sortRows orders rows by their inputs.
+func sortRows(rows []ResultRow) {
+	sort.Slice(rows, func(i, j int) bool { return lessInputs(rows[i].Inputs, rows[j].Inputs) })
+}
+
+// lessInputs orders query inputs lexicographically.
+func lessInputs(a, b []int) bool {
+	for n := 0; n < len(a) && n < len(b); n++ {
+		if a[n] != b[n] {
+			return a[n] < b[n]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// parseTime accepts RFC 3339 times, dates, or Unix timestamps.
+func parseTime(v string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// runFilter builds a RunFilter from the query parameters of r.
+func runFilter(r *http.Request) (RunFilter, error) {
+	params := r.URL.Query()
+	f := RunFilter{
+		Query:   params.Get("query"),
+		Cluster: params.Get("cluster"),
+	}
+	for param, value := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := params.Get(param); v != "" {
+			t, err := parseTime(v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %v: %q", param, v)
+			}
+			*value = t
+		}
+	}
+	for param, value := range map[string]*int{"concurrency": &f.Concurrency, "batchsize": &f.BatchSize, "limit": &f.Limit} {
+		if v := params.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return f, fmt.Errorf("invalid %v: %q", param, v)
+			}
+			*value = n
+		}
+	}
+	return f, nil
+}
+
+// HandleRuns lists stored runs, e.g. /runs?query=2.1&since=2017-11-01&limit=10
+func (s *Server) HandleRuns(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	f, err := runFilter(r)
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+	runs, err := s.store.ListRuns(f)
+	if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		fmt.Printf("writing runs to responsewriter: %v", err)
+	}
+}
+
+// HandleRun returns a single stored run.
+func (s *Server) HandleRun(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	run, err := s.store.Run(id)
+	if err != nil {
+		writeError(w, storeError(id, err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(run); err != nil {
+		fmt.Printf("writing run to responsewriter: %v", err)
+	}
+}
+
+// HandleRunResults returns the result rows of a stored run, sorted by input, as
+// JSON or, with format=csv or an Accept: text/csv header, as CSV.
+func (s *Server) HandleRunResults(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, errHistoryDisabled)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	run, err := s.store.Run(id)
+	if err != nil {
+		writeError(w, storeError(id, err))
+		return
+	}
+	rows, err := s.store.Rows(id)
+	if err != nil {
+		writeError(w, internalError(err))
+		return
+	}
+	sortRows(rows)
+	qs := getQuerySet(run.Result.Name)
+	result := RunRows{ID: id, Name: run.Result.Name, Labels: qs.Labels(), Rows: rows}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	switch format {
+	case "", "json":
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			fmt.Printf("writing run results to responsewriter: %v", err)
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := writeRowsCSV(w, result.Labels, rows); err != nil {
+			fmt.Printf("writing run results to responsewriter: %v", err)
+		}
+	default:
+		writeError(w, invalidParameter(fmt.Errorf("invalid format: %q, expected json or csv", format)))
+	}
+}
+
+// writeRowsCSV writes rows with a header of the input labels and "value".
+func writeRowsCSV(w io.Writer, labels []string, rows []ResultRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, labels...), "value")); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(row.Inputs)+1)
+		for _, in := range row.Inputs {
+			record = append(record, strconv.Itoa(in))
+		}
+		record = append(record, strconv.Itoa(row.Value))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
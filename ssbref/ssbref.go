@@ -0,0 +1,489 @@
+// Package ssbref computes expected answers to the demo's SSB queries directly
+// from the .tbl files written by the SSB dbgen, independently of Pilosa.
+//
+// Dimension tables are loaded into memory and lineorder is streamed, so memory use
+// depends on the dimension tables and the number of groups, not the scale factor.
+// Attributes are encoded as the row IDs the demo queries use: years as themselves,
+// months from 0, regions and nations in the order the demo lists them, cities as
+// nation*10 plus their digit, and manufacturers, categories and brands numbered
+// from 0 in the order of their codes (see EncodePart).
+package ssbref
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Regions and Nations are the IDs of SSB regions and nations. Each region's five
+// nations are numbered consecutively.
+var (
+	Regions = []string{"AMERICA", "AFRICA", "ASIA", "EUROPE", "MIDDLE EAST"}
+	Nations = []string{
+		"CANADA", "ARGENTINA", "BRAZIL", "UNITED STATES", "PERU",
+		"ETHIOPIA", "ALGERIA", "KENYA", "MOZAMBIQUE", "MOROCCO",
+		"INDIA", "INDONESIA", "CHINA", "VIETNAM", "JAPAN",
+		"ROMANIA", "RUSSIA", "FRANCE", "UNITED KINGDOM", "GERMANY",
+		"SAUDI ARABIA", "JORDAN", "IRAN", "IRAQ", "EGYPT",
+	}
+)
+
+// Place is the encoded location of a customer or supplier.
+type Place struct {
+	City, Nation, Region int
+}
+
+// Part is the encoded manufacturer, category and brand of a part.
+type Part struct {
+	Mfgr, Category, Brand int
+}
+
+// Date is the encoded calendar attributes of a date.
+type Date struct {
+	Year, Month, WeekNum int
+}
+
+// Row is a lineorder joined with its dimensions.
+type Row struct {
+	Customer      Place
+	Supplier      Place
+	Part          Part
+	Date          Date
+	Quantity      int
+	Discount      int
+	ExtendedPrice int64
+	Revenue       int64
+	SupplyCost    int64
+}
+
+// Dimensions holds the dimension tables, keyed by their primary keys.
+type Dimensions struct {
+	Customers map[int]Place
+	Suppliers map[int]Place
+	Parts     map[int]Part
+	Dates     map[int]Date
+}
+
+// scanTable calls fn with the fields of every line of a pipe-delimited .tbl file.
+func scanTable(fname string, fn func(fields []string) error) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "|")
+		if text == "" {
+			continue
+		}
+		if err := fn(strings.Split(text, "|")); err != nil {
+			return fmt.Errorf("%v:%d: %v", fname, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// fieldParser parses numeric fields of a line, remembering the first error.
+type fieldParser struct {
+	fields []string
+	err    error
+}
+
+func (p *fieldParser) intField(n int) int {
+	return int(p.int64Field(n))
+}
+
+func (p *fieldParser) int64Field(n int) int64 {
+	if p.err != nil {
+		return 0
+	}
+	if n >= len(p.fields) {
+		p.err = fmt.Errorf("missing field %d", n)
+		return 0
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(p.fields[n]), 10, 64)
+	if err != nil {
+		p.err = fmt.Errorf("field %d: %v", n, err)
+	}
+	return v
+}
+
+func (p *fieldParser) stringField(n int) string {
+	if p.err != nil {
+		return ""
+	}
+	if n >= len(p.fields) {
+		p.err = fmt.Errorf("missing field %d", n)
+		return ""
+	}
+	return strings.TrimSpace(p.fields[n])
+}
+
+func index(list []string, name string) (int, error) {
+	for i, s := range list {
+		if s == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown name %q", name)
+}
+
+// place encodes a city, nation and region, e.g. "UNITED KI1", "UNITED KINGDOM",
+// "EUROPE". dbgen names cities after the first 9 characters of their nation.
+func place(city, nation, region string) (Place, error) {
+	var p Place
+	var err error
+	if p.Nation, err = index(Nations, nation); err != nil {
+		return p, err
+	}
+	if p.Region, err = index(Regions, region); err != nil {
+		return p, err
+	}
+	if len(city) == 0 || !strings.HasPrefix(fmt.Sprintf("%-9.9s", nation), strings.TrimRight(city[:len(city)-1], " ")) {
+		return p, fmt.Errorf("city %q is not in %v", city, nation)
+	}
+	digit, err := strconv.Atoi(city[len(city)-1:])
+	if err != nil {
+		return p, fmt.Errorf("invalid city %q", city)
+	}
+	p.City = p.Nation*10 + digit
+	return p, nil
+}
+
+// loadPlaces reads customer.tbl or supplier.tbl, whose key, city, nation and
+// region are fields 0, 3, 4 and 5.
+func loadPlaces(fname string) (map[int]Place, error) {
+	places := make(map[int]Place)
+	err := scanTable(fname, func(fields []string) error {
+		p := fieldParser{fields: fields}
+		key := p.intField(0)
+		city, nation, region := p.stringField(3), p.stringField(4), p.stringField(5)
+		if p.err != nil {
+			return p.err
+		}
+		pl, err := place(city, nation, region)
+		places[key] = pl
+		return err
+	})
+	return places, err
+}
+
+// brandCode splits a code like "MFGR#2221" into its digits after "MFGR#": the
+// manufacturer, the category, and the brand.
+func brandCode(code string) (mfgr, category, brand int, err error) {
+	digits := strings.TrimPrefix(code, "MFGR#")
+	if digits == code || len(digits) < 1 {
+		return 0, 0, 0, fmt.Errorf("invalid code %q", code)
+	}
+	if mfgr, err = strconv.Atoi(digits[:1]); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid code %q", code)
+	}
+	if len(digits) >= 2 {
+		if category, err = strconv.Atoi(digits[1:2]); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid code %q", code)
+		}
+	}
+	if len(digits) >= 3 {
+		if brand, err = strconv.Atoi(digits[2:]); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid code %q", code)
+		}
+	}
+	return mfgr, category, brand, nil
+}
+
+// EncodePart encodes a brand code like "MFGR#1221" as the p_mfgr, p_category and
+// p_brand1 row IDs the demo queries use. Each is numbered from 0 in the order of
+// the codes, so there are 5 manufacturers, 25 categories and 1000 brands: MFGR#1
+// is manufacturer 0, MFGR#12 is category 5*0 + 1 and MFGR#1221 is brand 40*1 + 20.
+func EncodePart(code string) (Part, error) {
+	m, c, b, err := brandCode(code)
+	if err != nil {
+		return Part{}, err
+	}
+	mfgr := m - 1
+	category := 5*mfgr + (c - 1)
+	return Part{Mfgr: mfgr, Category: category, Brand: 40*category + (b - 1)}, nil
+}
+
+// loadParts reads part.tbl, whose key and brand are fields 0 and 4.
+func loadParts(fname string) (map[int]Part, error) {
+	parts := make(map[int]Part)
+	err := scanTable(fname, func(fields []string) error {
+		p := fieldParser{fields: fields}
+		key := p.intField(0)
+		brand := p.stringField(4)
+		if p.err != nil {
+			return p.err
+		}
+		part, err := EncodePart(brand)
+		parts[key] = part
+		return err
+	})
+	return parts, err
+}
+
+// loadDates reads date.tbl, whose key, year, month and week are fields 0, 4, 10 and 11.
+func loadDates(fname string) (map[int]Date, error) {
+	dates := make(map[int]Date)
+	err := scanTable(fname, func(fields []string) error {
+		p := fieldParser{fields: fields}
+		key := p.intField(0)
+		d := Date{Year: p.intField(4), Month: p.intField(10) - 1, WeekNum: p.intField(11)}
+		dates[key] = d
+		return p.err
+	})
+	return dates, err
+}
+
+// LoadDimensions reads customer.tbl, supplier.tbl, part.tbl and date.tbl from dir.
+func LoadDimensions(dir string) (*Dimensions, error) {
+	var d Dimensions
+	var err error
+	if d.Customers, err = loadPlaces(filepath.Join(dir, "customer.tbl")); err != nil {
+		return nil, err
+	}
+	if d.Suppliers, err = loadPlaces(filepath.Join(dir, "supplier.tbl")); err != nil {
+		return nil, err
+	}
+	if d.Parts, err = loadParts(filepath.Join(dir, "part.tbl")); err != nil {
+		return nil, err
+	}
+	if d.Dates, err = loadDates(filepath.Join(dir, "date.tbl")); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ScanLineOrders streams lineorder.tbl from dir, calling fn with each lineorder
+// joined with its dimensions.
+func (d *Dimensions) ScanLineOrders(dir string, fn func(Row)) error {
+	return scanTable(filepath.Join(dir, "lineorder.tbl"), func(fields []string) error {
+		p := fieldParser{fields: fields}
+		custKey, partKey, suppKey, orderDate := p.intField(2), p.intField(3), p.intField(4), p.intField(5)
+		row := Row{
+			Quantity:      p.intField(8),
+			ExtendedPrice: p.int64Field(9),
+			Discount:      p.intField(11),
+			Revenue:       p.int64Field(12),
+			SupplyCost:    p.int64Field(13),
+		}
+		if p.err != nil {
+			return p.err
+		}
+		var ok bool
+		if row.Customer, ok = d.Customers[custKey]; !ok {
+			return fmt.Errorf("unknown customer %d", custKey)
+		}
+		if row.Supplier, ok = d.Suppliers[suppKey]; !ok {
+			return fmt.Errorf("unknown supplier %d", suppKey)
+		}
+		if row.Part, ok = d.Parts[partKey]; !ok {
+			return fmt.Errorf("unknown part %d", partKey)
+		}
+		if row.Date, ok = d.Dates[orderDate]; !ok {
+			return fmt.Errorf("unknown date %d", orderDate)
+		}
+		fn(row)
+		return nil
+	})
+}
+
+// Query is an SSB query in the form the demo runs it: a filter over joined
+// lineorders, the inputs grouping them in the order of the demo's QuerySet
+// arguments, and the measure summed per group.
+type Query struct {
+	Filter func(Row) bool
+	Group  func(Row) []int
+	Value  func(Row) int64
+}
+
+// Result is the expected answer for one group of a query.
+type Result struct {
+	Inputs []int
+	Value  int64
+}
+
+func between(v, min, max int) bool { return v >= min && v <= max }
+
+func discountRevenue(r Row) int64 { return r.ExtendedPrice * int64(r.Discount) }
+func revenue(r Row) int64         { return r.Revenue }
+func profit(r Row) int64          { return r.Revenue - r.SupplyCost }
+
+var (
+	q11 = Query{
+		Filter: func(r Row) bool { return r.Date.Year == 1993 && between(r.Discount, 1, 3) && r.Quantity < 25 },
+		Group:  func(r Row) []int { return []int{r.Date.Year} },
+		Value:  discountRevenue,
+	}
+	q12 = Query{
+		Filter: func(r Row) bool {
+			return r.Date.Month == 0 && r.Date.Year == 1994 && between(r.Discount, 4, 6) && between(r.Quantity, 26, 35)
+		},
+		Group: func(r Row) []int { return []int{r.Date.Year} },
+		Value: discountRevenue,
+	}
+	q13 = Query{
+		Filter: func(r Row) bool {
+			return r.Date.WeekNum == 6 && r.Date.Year == 1994 && between(r.Discount, 5, 7) && between(r.Quantity, 26, 35)
+		},
+		Group: func(r Row) []int { return []int{r.Date.Year} },
+		Value: discountRevenue,
+	}
+	q41 = Query{
+		Filter: func(r Row) bool { return r.Supplier.Region == 0 && (r.Part.Mfgr == 0 || r.Part.Mfgr == 1) },
+		Group:  func(r Row) []int { return []int{r.Customer.Nation, r.Date.Year} },
+		Value:  profit,
+	}
+	q42 = Query{
+		Filter: func(r Row) bool { return r.Customer.Region == 0 },
+		Group:  func(r Row) []int { return []int{r.Part.Category, r.Supplier.Nation, r.Date.Year} },
+		Value:  profit,
+	}
+)
+
+// Queries maps the demo's query names to their reference implementations. Groups
+// outside a query's arguments are computed too and should be ignored.
+var Queries = map[string]Query{
+	"1.1": q11, "1.1b": q11, "1.1c": q11,
+	"1.2": q12, "1.2b": q12, "1.2c": q12,
+	"1.3": q13, "1.3b": q13, "1.3c": q13,
+	"2.1": {
+		Filter: func(r Row) bool { return r.Supplier.Region == 0 },
+		Group:  func(r Row) []int { return []int{r.Part.Brand, r.Date.Year} },
+		Value:  revenue,
+	},
+	"2.1r": {
+		Filter: func(r Row) bool { return r.Supplier.Region == 0 },
+		Group:  func(r Row) []int { return []int{r.Part.Brand, r.Date.Year} },
+		Value:  revenue,
+	},
+	"2.2": {
+		Filter: func(r Row) bool { return r.Supplier.Region == 2 },
+		Group:  func(r Row) []int { return []int{r.Part.Brand, r.Date.Year} },
+		Value:  revenue,
+	},
+	"2.3": {
+		Filter: func(r Row) bool { return r.Part.Brand == 260 && r.Supplier.Region == 3 },
+		Group:  func(r Row) []int { return []int{r.Date.Year} },
+		Value:  revenue,
+	},
+	"3.1": {
+		Group: func(r Row) []int { return []int{r.Customer.Nation, r.Supplier.Nation, r.Date.Year} },
+		Value: revenue,
+	},
+	"3.1r": {
+		Group: func(r Row) []int { return []int{r.Date.Year, r.Customer.Nation, r.Supplier.Nation} },
+		Value: revenue,
+	},
+	"3.2": {
+		Group: func(r Row) []int { return []int{r.Customer.City, r.Supplier.City, r.Date.Year} },
+		Value: revenue,
+	},
+	"3.2r": {
+		Group: func(r Row) []int { return []int{r.Date.Year, r.Customer.City, r.Supplier.City} },
+		Value: revenue,
+	},
+	"3.3": {
+		Group: func(r Row) []int { return []int{r.Customer.City, r.Supplier.City, r.Date.Year} },
+		Value: revenue,
+	},
+	"3.4": {
+		Filter: func(r Row) bool { return r.Date.Month == 11 && r.Date.Year == 1997 },
+		Group:  func(r Row) []int { return []int{r.Customer.City, r.Supplier.City} },
+		Value:  revenue,
+	},
+	"4.1": q41, "4.1r": q41, "4.1rb": q41,
+	"4.2": q42, "4.2r": q42,
+	"4.3": {
+		Filter: func(r Row) bool { return r.Customer.Region == 0 },
+		Group:  func(r Row) []int { return []int{r.Part.Brand, r.Supplier.City, r.Date.Year} },
+		Value:  profit,
+	},
+	"4.3r": {
+		Filter: func(r Row) bool { return r.Customer.Region == 0 },
+		Group:  func(r Row) []int { return []int{r.Part.Brand, r.Date.Year, r.Supplier.City} },
+		Value:  profit,
+	},
+}
+
+// Answers holds the computed results of each query, keyed by fmt.Sprint(inputs).
+// Groups with no matching lineorders are absent; their expected value is 0.
+type Answers struct {
+	LineOrders int
+	Results    map[string]map[string]Result
+}
+
+// Value returns the expected value of query for inputs.
+func (a *Answers) Value(query string, inputs []int) int64 {
+	return a.Results[query][fmt.Sprint(inputs)].Value
+}
+
+// Sorted returns the results of query sorted by inputs.
+func (a *Answers) Sorted(query string) []Result {
+	results := make([]Result, 0, len(a.Results[query]))
+	for _, r := range a.Results[query] {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		x, y := results[i].Inputs, results[j].Inputs
+		for n := 0; n < len(x) && n < len(y); n++ {
+			if x[n] != y[n] {
+				return x[n] < y[n]
+			}
+		}
+		return len(x) < len(y)
+	})
+	return results
+}
+
+// Compute answers the named queries, or all of Queries if none are given, in a
+// single pass over the dbgen files in dir.
+func Compute(dir string, names ...string) (*Answers, error) {
+	if len(names) == 0 {
+		for name := range Queries {
+			names = append(names, name)
+		}
+	}
+	queries := make([]Query, len(names))
+	answers := &Answers{Results: make(map[string]map[string]Result, len(names))}
+	for n, name := range names {
+		q, ok := Queries[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown query %q", name)
+		}
+		queries[n] = q
+		answers.Results[name] = make(map[string]Result)
+	}
+
+	dims, err := LoadDimensions(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = dims.ScanLineOrders(dir, func(row Row) {
+		answers.LineOrders++
+		for n, q := range queries {
+			if q.Filter != nil && !q.Filter(row) {
+				continue
+			}
+			inputs := q.Group(row)
+			key := fmt.Sprint(inputs)
+			results := answers.Results[names[n]]
+			r, ok := results[key]
+			if !ok {
+				r.Inputs = inputs
+			}
+			r.Value += q.Value(row)
+			results[key] = r
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return answers, nil
+}
@@ -0,0 +1,124 @@
+package ssbref
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tables is a tiny SSB dataset in dbgen's format. Only the fields ssbref reads
+// are meaningful.
+var tables = map[string][]string{
+	"customer.tbl": {
+		"1|Customer#1|addr|CANADA   1|CANADA|AMERICA|phone|BUILDING|",         // city 1, nation 0, region 0
+		"2|Customer#2|addr|UNITED KI3|UNITED KINGDOM|EUROPE|phone|MACHINERY|", // city 183, nation 18, region 3
+	},
+	"supplier.tbl": {
+		"1|Supplier#1|addr|PERU     2|PERU|AMERICA|phone|",  // city 42, nation 4, region 0
+		"2|Supplier#2|addr|CHINA    0|CHINA|ASIA|phone|",    // city 120, nation 12, region 2
+		"3|Supplier#3|addr|FRANCE   5|FRANCE|EUROPE|phone|", // city 175, nation 17, region 3
+	},
+	"part.tbl": {
+		"1|lace|MFGR#1|MFGR#12|MFGR#121|red|STANDARD|1|SM BOX|",  // mfgr 0, category 1, brand 40
+		"2|lace|MFGR#2|MFGR#22|MFGR#2221|red|STANDARD|1|SM BOX|", // mfgr 1, category 6, brand 260
+		"3|lace|MFGR#3|MFGR#34|MFGR#3410|red|STANDARD|1|SM BOX|", // mfgr 2, category 13, brand 529
+	},
+	"date.tbl": {
+		"19930115|January 15, 1993|Friday|January|1993|199301|Jan1993|6|15|15|1|3|Winter|0|1|0|1|",
+		"19940207|February 7, 1994|Monday|February|1994|199402|Feb1994|2|7|38|2|6|Winter|0|0|0|1|",
+		"19971210|December 10, 1997|Wednesday|December|1997|199712|Dec1997|4|10|344|12|50|Winter|0|0|0|1|",
+	},
+	// order|line|cust|part|supp|date|priority|ship|quantity|price|total|discount|revenue|cost|tax|commit|mode
+	"lineorder.tbl": {
+		"1|1|1|1|1|19930115|1-URGENT|0|10|1000|0|2|980|600|0|19930201|AIR|",
+		"1|2|1|2|1|19930115|1-URGENT|0|30|500|0|1|495|100|0|19930201|AIR|",
+		"2|1|2|3|1|19930115|1-URGENT|0|5|300|0|3|291|91|0|19930201|AIR|",
+		"3|1|2|2|3|19971210|1-URGENT|0|20|700|0|2|686|200|0|19971230|AIR|",
+		"4|1|1|1|2|19940207|1-URGENT|0|30|400|0|5|380|80|0|19940301|AIR|",
+	},
+}
+
+func writeTables(t *testing.T) string {
+	dir := t.TempDir()
+	for name, lines := range tables {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestPartEncoding pins part codes to the row IDs of the p_mfgr, p_category and
+// p_brand1 frames, which are numbered from 0.
+func TestPartEncoding(t *testing.T) {
+	dims, err := LoadDimensions(writeTables(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]Part{
+		1: {Mfgr: 0, Category: 1, Brand: 40},
+		2: {Mfgr: 1, Category: 6, Brand: 260},
+		3: {Mfgr: 2, Category: 13, Brand: 529},
+	}
+	if !reflect.DeepEqual(dims.Parts, want) {
+		t.Errorf("got parts %v, want %v", dims.Parts, want)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	// Worked by hand from the lineorders above: discounted price for flight 1,
+	// revenue for flights 2 and 3, and revenue - supplycost for flight 4.
+	want := map[string][]Result{
+		"1.1": {{[]int{1993}, 1000*2 + 300*3}},
+		"1.2": {},
+		"1.3": {{[]int{1994}, 400 * 5}},
+		"2.1": {{[]int{40, 1993}, 980}, {[]int{260, 1993}, 495}, {[]int{529, 1993}, 291}},
+		"2.2": {{[]int{40, 1994}, 380}},
+		"2.3": {{[]int{1997}, 686}},
+		"3.1": {
+			{[]int{0, 4, 1993}, 980 + 495},
+			{[]int{0, 12, 1994}, 380},
+			{[]int{18, 4, 1993}, 291},
+			{[]int{18, 17, 1997}, 686},
+		},
+		"3.4": {{[]int{183, 175}, 686}},
+		"4.1": {{[]int{0, 1993}, (980 - 600) + (495 - 100)}},
+		"4.2": {
+			{[]int{1, 4, 1993}, 980 - 600},
+			{[]int{1, 12, 1994}, 380 - 80},
+			{[]int{6, 4, 1993}, 495 - 100},
+		},
+		"4.3": {
+			{[]int{40, 42, 1993}, 980 - 600},
+			{[]int{40, 120, 1994}, 380 - 80},
+			{[]int{260, 42, 1993}, 495 - 100},
+		},
+	}
+	names := make([]string, 0, len(want))
+	for name := range want {
+		names = append(names, name)
+	}
+	answers, err := Compute(writeTables(t), names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answers.LineOrders != 5 {
+		t.Errorf("got %d lineorders, want 5", answers.LineOrders)
+	}
+	for name, results := range want {
+		if got := answers.Sorted(name); !reflect.DeepEqual(got, results) {
+			t.Errorf("%v: got %v, want %v", name, got, results)
+		}
+	}
+	if v := answers.Value("4.1", []int{18, 1993}); v != 0 {
+		t.Errorf("got %v for a group with no lineorders, want 0", v)
+	}
+}
+
+func TestComputeUnknownQuery(t *testing.T) {
+	if _, err := Compute(writeTables(t), "9.9"); err == nil {
+		t.Error("got no error for an unknown query")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	runsBucket      = []byte("runs")
+	rowsBucket      = []byte("rows")
+	baselinesBucket = []byte("baselines") // query name -> run ID
+)
+
+// RunFilter selects stored runs. Zero fields match everything.
+type RunFilter struct {
+	Query       string
+	Cluster     string
+	Since       time.Time
+	Until       time.Time
+	Concurrency int
+	BatchSize   int
+	Limit       int
+}
+
+func (f RunFilter) matches(run StoredRun) bool {
+	switch {
+	case f.Query != "" && run.Result.Name != f.Query,
+		f.Cluster != "" && run.Result.Cluster != f.Cluster,
+		!f.Since.IsZero() && run.Time.Before(f.Since),
+		!f.Until.IsZero() && run.Time.After(f.Until),
+		f.Concurrency != 0 && run.Result.Concurrency != f.Concurrency,
+		f.BatchSize != 0 && run.Result.BatchSize != f.BatchSize:
+		return false
+	}
+	return true
+}
+
+// Store is an on-disk history of benchmark runs, kept in a BoltDB file.
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens or creates the store at path.
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening store %v: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, rowsBucket, baselinesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing store %v: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (st *Store) Close() error {
+	return st.db.Close()
+}
+
+// SaveRun records a run and its result rows.
+func (st *Store) SaveRun(run StoredRun, rows []ResultRow) error {
+	runJSON, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	return st.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(runsBucket).Put([]byte(run.ID), runJSON); err != nil {
+			return err
+		}
+		return tx.Bucket(rowsBucket).Put([]byte(run.ID), rowsJSON)
+	})
+}
+
+// ErrRunNotFound is returned for an unknown run ID.
+var ErrRunNotFound = errors.New("run not found")
+
+// Run returns the stored run with the given ID.
+func (st *Store) Run(id string) (StoredRun, error) {
+	var run StoredRun
+	err := st.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(runsBucket).Get([]byte(id))
+		if v == nil {
+			return ErrRunNotFound
+		}
+		return json.Unmarshal(v, &run)
+	})
+	return run, err
+}
+
+// Rows returns the result rows of the run with the given ID.
+func (st *Store) Rows(id string) ([]ResultRow, error) {
+	var rows []ResultRow
+	err := st.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(rowsBucket).Get([]byte(id))
+		if v == nil {
+			return ErrRunNotFound
+		}
+		return json.Unmarshal(v, &rows)
+	})
+	return rows, err
+}
+
+// ListRuns returns the runs matching f, newest first.
+func (st *Store) ListRuns(f RunFilter) ([]StoredRun, error) {
+	runs := []StoredRun{}
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+			var run StoredRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("decoding run %s: %v", k, err)
+			}
+			if f.matches(run) {
+				runs = append(runs, run)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Time.After(runs[j].Time) })
+	if f.Limit > 0 && len(runs) > f.Limit {
+		runs = runs[:f.Limit]
+	}
+	return runs, nil
+}
+
+// SetBaseline makes the run with the given ID the baseline for query.
+func (st *Store) SetBaseline(query, id string) error {
+	return st.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(baselinesBucket).Put([]byte(query), []byte(id))
+	})
+}
+
+// Baseline returns the baseline run of query, or ErrRunNotFound if it has none.
+func (st *Store) Baseline(query string) (StoredRun, error) {
+	var id []byte
+	st.db.View(func(tx *bolt.Tx) error {
+		id = append(id, tx.Bucket(baselinesBucket).Get([]byte(query))...)
+		return nil
+	})
+	if id == nil {
+		return StoredRun{}, ErrRunNotFound
+	}
+	return st.Run(string(id))
+}
+
+// Baselines returns the baseline run ID of every query that has one.
+func (st *Store) Baselines() (map[string]string, error) {
+	baselines := make(map[string]string)
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(baselinesBucket).ForEach(func(k, v []byte) error {
+			baselines[string(k)] = string(v)
+			return nil
+		})
+	})
+	return baselines, err
+}
+
+// recordRun saves a finished run to the store, if there is one.
+func (s *Server) recordRun(bench benchmarkRun) {
+	if s.store == nil || bench.RunID == "" {
+		return
+	}
+	run := StoredRun{
+		ID:     bench.RunID,
+		Time:   time.Unix(int64(bench.Timestamp), 0),
+		Result: bench.BenchmarkResult,
+	}
+	if err := s.store.SaveRun(run, bench.rows); err != nil {
+		fmt.Printf("saving run %v: %v\n", bench.RunID, err)
+	}
+}
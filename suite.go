@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// suites maps suite names to the queries they run, in order.
+var suites = map[string][]string{
+	"ssb": ssbQueries,
+	"all": {
+		"1.1", "1.2", "1.3", "1.1b", "1.2b", "1.3b", "1.1c", "1.2c", "1.3c",
+		"2.1", "2.2", "2.3",
+		"3.1", "3.2", "3.3", "3.4",
+		"4.1", "4.2", "4.3",
+	},
+	"reg": {"2.1r", "3.1r", "3.2r", "4.1r", "4.1rb", "4.2r", "4.3r"},
+}
+
+// RunSuite runs every query of a suite in order with the same settings. GeoMean is
+// the geometric mean of the per-query times, over the queries that did not fail.
+func (s *Server) RunSuite(name string, queries []string, opts RunOptions, run *QueuedRun) SuiteResult {
+	sr := SuiteResult{
+		Name:        name,
+		Concurrency: opts.Concurrency,
+		BatchSize:   opts.BatchSize,
+		Timestamp:   int32(time.Now().Unix()),
+	}
+	start := time.Now()
+	logSum := 0.0
+	for _, qname := range queries {
+		res := s.RunSumMultiBatch(getQuerySet(qname), opts, run)
+		sr.Results = append(sr.Results, res.BenchmarkResult)
+		if res.Seconds <= 0 || res.Aborted || res.DeadlineExceeded {
+			sr.Failed++
+			continue
+		}
+		logSum += math.Log(res.Seconds)
+	}
+	sr.Seconds = time.Since(start).Seconds()
+	var checks []RegressionCheck
+	for _, res := range sr.Results {
+		if res.Verification != nil && !res.Verification.Pass {
+			sr.Unverified = append(sr.Unverified, res.Name)
+		}
+		if res.Regression != nil {
+			checks = append(checks, *res.Regression)
+		}
+	}
+	if len(checks) > 0 {
+		sr.Gate = newGateResult(checks)
+	}
+	if n := len(queries) - sr.Failed; n > 0 {
+		sr.GeoMean = math.Exp(logSum / float64(n))
+	}
+	return sr
+}
+
+// HandleSuite runs a named suite (default "ssb"), e.g. /suite/all?concurrency=8&batchsize=4
+func (s *Server) HandleSuite(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("handling %v\n", r.URL)
+	name := mux.Vars(r)["name"]
+	if name == "" {
+		name = "ssb"
+	}
+	queries, ok := suites[name]
+	if !ok {
+		writeError(w, APIError{
+			Status:  http.StatusNotFound,
+			Code:    codeUnknownSuite,
+			Message: fmt.Sprintf("unknown suite: %q", name),
+		})
+		return
+	}
+
+	opts, err := s.runOptions(r)
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+
+	run, err := s.queue.Acquire(r.Context(), r.URL.Path)
+	if err != nil {
+		fmt.Printf("%v left the queue: %v\n", r.URL, err)
+		return
+	}
+	result := s.RunSuite(name, queries, opts, run)
+	result.Overlapped = s.queue.Release(run)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		fmt.Printf("writing suite result: %v to responsewriter: %v", result, err)
+	}
+}
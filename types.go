@@ -0,0 +1,36 @@
+package main
+
+import "github.com/pilosa/demo-ssb/client"
+
+// The JSON bodies of the API's requests and responses are defined in the client
+// package, so the server and its clients share one definition of each.
+type (
+	APIError         = client.APIError
+	BenchmarkResult  = client.BenchmarkResult
+	ClusterTiming    = client.ClusterTiming
+	Comparison       = client.Comparison
+	DiffSummary      = client.DiffSummary
+	GateResult       = client.GateResult
+	LatencyStats     = client.LatencyStats
+	NodeMetrics      = client.NodeMetrics
+	PhaseTimes       = client.PhaseTimes
+	QueryInfo        = client.QueryInfo
+	QueryList        = client.QueryList
+	QueueState       = client.QueueState
+	RegressionCheck  = client.RegressionCheck
+	RegressionPolicy = client.RegressionPolicy
+	ResultRow        = client.ResultRow
+	RowMismatch      = client.RowMismatch
+	RunDiff          = client.RunDiff
+	RunMetadata      = client.RunMetadata
+	RunOptions       = client.RunOptions
+	RunRows          = client.RunRows
+	ServerMetrics    = client.ServerMetrics
+	ServerSettings   = client.ServerSettings
+	StoredRun        = client.StoredRun
+	SuiteResult      = client.SuiteResult
+	Verification     = client.Verification
+	ValueDiff        = client.ValueDiff
+	VersionInfo      = client.VersionInfo
+	WorkloadResult   = client.WorkloadResult
+)
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkloadEntry is one weighted component of a Workload. Queries for an entry are
+// drawn uniformly from its QuerySets.
+type WorkloadEntry struct {
+	Pattern   string
+	Weight    float64
+	QuerySets []QuerySet
+}
+
+// Workload is a weighted mix of QuerySets sharing a single worker pool, similar to
+// the TPC throughput test.
+type Workload struct {
+	Mix     string
+	Entries []WorkloadEntry
+}
+
+// ParseWorkload parses a mix such as "40:2.x,30:3.x,30:4.x". Each entry is a weight
+// and either a query name or a flight ("2.x"), which expands to the standard SSB
+// queries of that flight. Weights are relative and need not sum to 100.
+func ParseWorkload(mix string) (Workload, error) {
+	wl := Workload{Mix: mix}
+	for _, part := range strings.Split(mix, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(fields) != 2 {
+			return wl, fmt.Errorf("workload entry %q: expected weight:query", part)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "%"), 64)
+		if err != nil || weight <= 0 {
+			return wl, fmt.Errorf("workload entry %q: invalid weight", part)
+		}
+		entry := WorkloadEntry{Pattern: fields[1], Weight: weight}
+		for _, qname := range expandQueryPattern(fields[1]) {
+			qs := getQuerySet(qname)
+			if qs.Name == "" {
+				return wl, fmt.Errorf("workload entry %q: unknown query %v", part, qname)
+			}
+			entry.QuerySets = append(entry.QuerySets, qs)
+		}
+		wl.Entries = append(wl.Entries, entry)
+	}
+	return wl, nil
+}
+
+// expandQueryPattern expands a flight pattern like "3.x" to its SSB queries.
+// Any other pattern is returned as a single query name.
+func expandQueryPattern(pattern string) []string {
+	if !strings.HasSuffix(pattern, ".x") {
+		return []string{pattern}
+	}
+	prefix := strings.TrimSuffix(pattern, "x")
+	var names []string
+	for _, qname := range ssbQueries {
+		if strings.HasPrefix(qname, prefix) {
+			names = append(names, qname)
+		}
+	}
+	if names == nil {
+		return []string{pattern}
+	}
+	return names
+}
+
+// workloadQuery is a single draw from a Workload.
+type workloadQuery struct {
+	set     int // index into the flattened query set list
+	result  QueryResult
+	latency time.Duration
+}
+
+// newLatencyStats computes summary statistics over a set of latencies.
+func newLatencyStats(name string, latencies []time.Duration) LatencyStats {
+	stats := LatencyStats{Name: name, Queries: len(latencies)}
+	if len(latencies) == 0 {
+		return stats
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(len(sorted)-1))].Seconds()
+	}
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	stats.Mean = total.Seconds() / float64(len(sorted))
+	stats.Min = sorted[0].Seconds()
+	stats.P50 = percentile(0.50)
+	stats.P95 = percentile(0.95)
+	stats.P99 = percentile(0.99)
+	stats.Max = sorted[len(sorted)-1].Seconds()
+	return stats
+}
+
+// RunWorkload sends count queries drawn from a Workload through a shared pool of
+// opts.Concurrency workers, spread across the nodes of opts.Cluster. Each query is
+// sent as its own request so its latency can be attributed to its QuerySet. The
+// draws are made up front from opts.Seed. Failed queries are retried and handled
+// according to s.errorPolicy, and the run stops when s.runTimeout expires.
+func (s *Server) RunWorkload(wl Workload, count int, opts RunOptions) WorkloadResult {
+	c, ok := s.Clusters[opts.Cluster]
+	if !ok {
+		c = s.Cluster
+	}
+	timestamp := int32(time.Now().Unix())
+	wr := WorkloadResult{
+		Mix:         wl.Mix,
+		Concurrency: opts.Concurrency,
+		Seed:        opts.Seed,
+		Cluster:     c.Name,
+		ColumnCount: c.NumLineOrders,
+		Timestamp:   timestamp,
+	}
+
+	// Flatten query sets and draw the query sequence.
+	var sets []QuerySet
+	var entryOf []int
+	var cumulative []float64
+	total := 0.0
+	for i, entry := range wl.Entries {
+		total += entry.Weight
+		cumulative = append(cumulative, total)
+		for _, qs := range entry.QuerySets {
+			sets = append(sets, qs)
+			entryOf = append(entryOf, i)
+		}
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	draws := make([]workloadQuery, count)
+	for n := range draws {
+		e := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+		var candidates []int
+		for i := range sets {
+			if entryOf[i] == e {
+				candidates = append(candidates, i)
+			}
+		}
+		set := candidates[rng.Intn(len(candidates))]
+		draws[n] = workloadQuery{set: set, result: sets[set].QueryResultN(rng.Intn(sets[set].iterations))}
+	}
+
+	f, _, err := createResultsFile("workload", timestamp, ".txt")
+	if err != nil {
+		fmt.Printf("creating results file: %v\n", err)
+		wr.Seconds = -1
+		return wr
+	}
+	defer f.Close()
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+	queries := make(chan int)
+	results := make(chan workloadQuery)
+	go func() {
+		defer close(queries)
+		for n := range draws {
+			select {
+			case queries <- n:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for _, qs := range sets {
+		if qs.setup != "" {
+			if _, _, err := s.queryWithRetry(c.Nodes[0], qs.setup, done); err != nil {
+				fmt.Printf("error in setup: %v\n", err)
+				stop()
+				wr.Seconds = -1
+				return wr
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < opts.Concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range queries {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				q := draws[n]
+				node := c.pickNode(opts.Balance)
+				qstart := time.Now()
+				response, qstats, err := s.queryWithRetry(node, q.result.raw, done)
+				q.latency = time.Since(qstart)
+				if err == nil && len(response.Results) != 1 {
+					err = &queryError{Status: http.StatusOK, Message: fmt.Sprintf("got %d results for 1 query", len(response.Results))}
+				}
+				s.metrics.observeBatch(sets[q.set].Name, node.Addr, 1, qstats.retries, q.latency, err)
+				if err != nil {
+					q.result.err = err
+				} else {
+					q.result.outputs = []interface{}{response.Results[0].sum()}
+				}
+				results <- q
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var deadline <-chan time.Time
+	if s.runTimeout > 0 {
+		timer := time.NewTimer(s.runTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// On abort, keep draining so workers can exit, and keep counting the queries
+	// that were already in flight, except those the abort cancelled.
+	latencies := make([][]time.Duration, len(sets))
+	stats := make([]LatencyStats, len(sets))
+	for results != nil {
+		var q workloadQuery
+		var ok bool
+		select {
+		case q, ok = <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+		case <-deadline:
+			fmt.Printf("run deadline exceeded for workload %v\n", wl.Mix)
+			wr.DeadlineExceeded = true
+			deadline = nil
+			stop()
+			continue
+		}
+		switch {
+		case q.result.err == errBatchTimeout:
+			if wr.Aborted {
+				continue // cancelled by the abort
+			}
+			wr.TimedOut++
+			stats[q.set].TimedOut++
+		case q.result.err != nil:
+			wr.Failed++
+			stats[q.set].Failed++
+			if !s.errorPolicy.Continue && !wr.Aborted {
+				fmt.Printf("aborting workload: %v\n", q.result.err)
+				wr.Aborted = true
+				stop()
+			}
+		default:
+			wr.Completed++
+			latencies[q.set] = append(latencies[q.set], q.latency)
+			if wr.Aborted {
+				break
+			}
+			if _, err := f.WriteString(fmt.Sprintf("%v %v %v\n", sets[q.set].Name, q.result.outputs[0], q.result.inputs)); err != nil {
+				fmt.Printf("writing results file: %v\n", err)
+				wr.Aborted = true
+				stop()
+			}
+		}
+		wr.Queries++
+	}
+	wr.Seconds = time.Since(start).Seconds()
+
+	for _, qs := range sets {
+		if qs.teardown != "" {
+			if _, _, err := s.queryWithRetry(c.Nodes[0], qs.teardown, nil); err != nil {
+				fmt.Printf("error in teardown: %v\n", err)
+			}
+		}
+	}
+
+	if wr.Aborted {
+		wr.Seconds = -1
+	} else {
+		wr.QueriesPerSecond = float64(wr.Completed) / wr.Seconds
+	}
+	for i, qs := range sets {
+		ls := newLatencyStats(qs.Name, latencies[i])
+		ls.Failed, ls.TimedOut = stats[i].Failed, stats[i].TimedOut
+		wr.Sets = append(wr.Sets, ls)
+	}
+	return wr
+}
+
+// HandleWorkload runs a weighted mix of query sets, e.g.
+// /workload?mix=40:2.x,30:3.x,30:4.x&queries=1000&seed=1
+func (s *Server) HandleWorkload(w http.ResponseWriter, r *http.Request) {
+	fmt.Printf("handling %v\n", r.URL)
+	params := r.URL.Query()
+	wl, err := ParseWorkload(params.Get("mix"))
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+	count := 1000
+	if v := params.Get("queries"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil || count <= 0 {
+			writeError(w, invalidParameter(fmt.Errorf("invalid queries: %q", v)))
+			return
+		}
+	}
+	opts, err := s.runOptions(r)
+	if err != nil {
+		writeError(w, invalidParameter(err))
+		return
+	}
+	if params.Get("seed") == "" {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	run, err := s.queue.Acquire(r.Context(), r.URL.Path)
+	if err != nil {
+		fmt.Printf("%v left the queue: %v\n", r.URL, err)
+		return
+	}
+	result := s.RunWorkload(wl, count, opts)
+	result.Overlapped = s.queue.Release(run)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		fmt.Printf("writing workload result: %v to responsewriter: %v", result, err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpandQueryPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"1.x", []string{"1.1", "1.2", "1.3"}},
+		{"3.x", []string{"3.1", "3.2", "3.3", "3.4"}},
+		{"2.1", []string{"2.1"}},
+		{"9.x", []string{"9.x"}},
+		{"4.1r", []string{"4.1r"}},
+	}
+	for _, test := range tests {
+		if got := expandQueryPattern(test.pattern); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expandQueryPattern(%q) = %v, want %v", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		mix         string
+		wantWeights []float64
+		wantSets    []int // query sets per entry
+		wantErr     bool
+	}{
+		{"40:2.x,30:3.x,30:4.x", []float64{40, 30, 30}, []int{3, 4, 3}, false},
+		{"50%:1.1, 50%:4.1", []float64{50, 50}, []int{1, 1}, false},
+		{"1:1.x", []float64{1}, []int{3}, false},
+		{"", nil, nil, true},
+		{"2.x", nil, nil, true},
+		{"0:2.x", nil, nil, true},
+		{"-5:2.x", nil, nil, true},
+		{"x:2.x", nil, nil, true},
+		{"10:9.9", nil, nil, true},
+		{"10:9.x", nil, nil, true},
+	}
+	for _, test := range tests {
+		wl, err := ParseWorkload(test.mix)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseWorkload(%q): got error %v, want error: %v", test.mix, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var weights []float64
+		var sets []int
+		for _, entry := range wl.Entries {
+			weights = append(weights, entry.Weight)
+			sets = append(sets, len(entry.QuerySets))
+		}
+		if !reflect.DeepEqual(weights, test.wantWeights) || !reflect.DeepEqual(sets, test.wantSets) {
+			t.Errorf("ParseWorkload(%q): got weights %v and query sets %v, want %v and %v", test.mix, weights, sets, test.wantWeights, test.wantSets)
+		}
+	}
+}
+
+func TestNewLatencyStats(t *testing.T) {
+	var hundred []time.Duration
+	for n := 100; n >= 1; n-- {
+		hundred = append(hundred, time.Duration(n)*time.Millisecond)
+	}
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      LatencyStats
+	}{
+		{"none", nil, LatencyStats{Name: "none"}},
+		{"one", []time.Duration{2 * time.Second}, LatencyStats{Name: "one", Queries: 1, Mean: 2, Min: 2, P50: 2, P95: 2, P99: 2, Max: 2}},
+		{"two", []time.Duration{3 * time.Second, time.Second}, LatencyStats{Name: "two", Queries: 2, Mean: 2, Min: 1, P50: 1, P95: 1, P99: 1, Max: 3}},
+		{"hundred", hundred, LatencyStats{Name: "hundred", Queries: 100, Mean: 0.0505, Min: 0.001, P50: 0.050, P95: 0.095, P99: 0.099, Max: 0.1}},
+	}
+	for _, test := range tests {
+		got := newLatencyStats(test.name, test.latencies)
+		got.Mean = float64(int64(got.Mean*1e6+0.5)) / 1e6
+		if got != test.want {
+			t.Errorf("%v: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+	if hundred[0] != 100*time.Millisecond {
+		t.Errorf("newLatencyStats reordered its argument")
+	}
+}
+
+func TestRunWorkload(t *testing.T) {
+	wl := Workload{Mix: "test", Entries: []WorkloadEntry{
+		{Pattern: "a", Weight: 1, QuerySets: []QuerySet{
+			NewQuerySet("a", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_year", rowID=%d))`, [][]int{{1, 2, 3, 4}}),
+		}},
+		{Pattern: "b", Weight: 1, QuerySets: []QuerySet{
+			NewQuerySet("b", `Sum(frame="lo_revenue", field="field_lo_revenue", Bitmap(frame="lo_month", rowID=%d))`, [][]int{{5, 6}}),
+		}},
+	}}
+	opts := RunOptions{Concurrency: 2, Balance: "round-robin", Seed: 1}
+	tests := []struct {
+		name         string
+		stub         *pilosaStub
+		policy       ErrorPolicy
+		runTimeout   time.Duration
+		wantAborted  bool
+		wantDeadline bool
+	}{
+		{"complete", &pilosaStub{}, ErrorPolicy{}, 0, false, false},
+		{"continue", &pilosaStub{failOn: "rowID=2)"}, ErrorPolicy{Continue: true}, 0, false, false},
+		{"abort", &pilosaStub{fail: 1000}, ErrorPolicy{}, 0, true, false},
+		{"deadline", &pilosaStub{delay: 30 * time.Millisecond}, ErrorPolicy{}, 100 * time.Millisecond, false, true},
+	}
+	for _, test := range tests {
+		srv := httptest.NewServer(test.stub)
+		s := newTestServer(t, srv.Listener.Addr().String())
+		s.errorPolicy = test.policy
+		s.runTimeout = test.runTimeout
+
+		wr := s.RunWorkload(wl, 40, opts)
+		srv.Close()
+		if wr.Aborted != test.wantAborted || wr.DeadlineExceeded != test.wantDeadline {
+			t.Errorf("%v: got aborted %v, deadline exceeded %v, want %v, %v", test.name, wr.Aborted, wr.DeadlineExceeded, test.wantAborted, test.wantDeadline)
+		}
+		if wr.Queries != wr.Completed+wr.Failed+wr.TimedOut {
+			t.Errorf("%v: got %d queries, but %d completed, %d failed and %d timed out", test.name, wr.Queries, wr.Completed, wr.Failed, wr.TimedOut)
+		}
+		if stopped := test.wantAborted || test.wantDeadline; (wr.Queries < 40) != stopped {
+			t.Errorf("%v: got %d of 40 queries", test.name, wr.Queries)
+		}
+		if len(test.stub.requests) < wr.Queries {
+			t.Errorf("%v: reported %d queries for %d requests", test.name, wr.Queries, len(test.stub.requests))
+		}
+		var completed, failed, timedOut int
+		for _, ls := range wr.Sets {
+			completed += ls.Queries
+			failed += ls.Failed
+			timedOut += ls.TimedOut
+		}
+		if completed != wr.Completed || failed != wr.Failed || timedOut != wr.TimedOut {
+			t.Errorf("%v: sets add up to %d completed, %d failed, %d timed out, want %d, %d, %d", test.name,
+				completed, failed, timedOut, wr.Completed, wr.Failed, wr.TimedOut)
+		}
+		switch {
+		case test.wantAborted:
+			if wr.Failed == 0 || wr.Seconds != -1 {
+				t.Errorf("%v: got %d failed in %v seconds", test.name, wr.Failed, wr.Seconds)
+			}
+		case test.wantDeadline:
+			if wr.TimedOut == 0 || wr.TimedOut > opts.Concurrency {
+				t.Errorf("%v: got %d timed out, want the ones in flight, at most %d", test.name, wr.TimedOut, opts.Concurrency)
+			}
+		case test.policy.Continue:
+			if wr.Failed == 0 || wr.Completed == 0 {
+				t.Errorf("%v: got %d completed and %d failed, want some of each", test.name, wr.Completed, wr.Failed)
+			}
+		}
+		if wr.Seconds > 0 && wr.QueriesPerSecond != float64(wr.Completed)/wr.Seconds {
+			t.Errorf("%v: got %v qps for %d completed in %v seconds", test.name, wr.QueriesPerSecond, wr.Completed, wr.Seconds)
+		}
+	}
+}